@@ -0,0 +1,88 @@
+package service
+
+import "reflect"
+
+// lookupType returns the entry whose service is assignable to the given type along with the
+// container layer that holds the entry. A service registered via Provide for exactly the given
+// type takes precedence. Otherwise, every service visible from this container whose type is known
+// without invoking a factory is considered. The returned flag is false if no such service exists,
+// and an error is returned if more than one service matches.
+func (c *Container) lookupType(t reflect.Type) (*entry, *Container, bool, error) {
+	if e, owner, ok := c.lookup(TypeKey(t)); ok {
+		return e, owner, true, nil
+	}
+
+	var matches []candidate
+	for _, candidate := range c.visibleEntries() {
+		if typ := candidate.e.typeOf(); typ != nil && typ.AssignableTo(t) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil, false, nil
+	case 1:
+		return matches[0].e, matches[0].owner, true, nil
+	}
+
+	keys := make([]interface{}, 0, len(matches))
+	for _, match := range matches {
+		keys = append(keys, match.e.key)
+	}
+
+	return nil, nil, false, &AmbiguousTypeError{Type: t, Keys: keys}
+}
+
+// candidate is an entry along with the container layer that holds it.
+type candidate struct {
+	e     *entry
+	owner *Container
+}
+
+// visibleEntries returns the entries that can be retrieved from this container, ordered from the
+// nearest layer to the root and by registration order within each layer. Entries of parent layers
+// that are shadowed by an entry with an equivalent key in a nearer layer are omitted.
+func (c *Container) visibleEntries() []candidate {
+	seenKeys := map[interface{}]struct{}{}
+	seenTags := map[string]struct{}{}
+
+	var candidates []candidate
+	for layer := c; layer != nil; layer = layer.parent {
+		layerEntries := layer.localEntries()
+		for _, e := range layerEntries {
+			if _, ok := seenKeys[e.key]; ok {
+				continue
+			}
+			if tag, ok := tagForKey(e.key); ok {
+				if _, ok := seenTags[tag]; ok {
+					continue
+				}
+			}
+
+			candidates = append(candidates, candidate{e, layer})
+		}
+		for _, e := range layerEntries {
+			seenKeys[e.key] = struct{}{}
+			if tag, ok := tagForKey(e.key); ok {
+				seenTags[tag] = struct{}{}
+			}
+		}
+	}
+
+	return candidates
+}
+
+// typeOf returns the type of the service held by the entry if it can be determined without
+// invoking a factory, and nil otherwise.
+func (e *entry) typeOf() reflect.Type {
+	if e.typ != nil {
+		return e.typ
+	}
+
+	if e.factory == nil && e.instance.value != nil {
+		return reflect.TypeOf(e.instance.value)
+	}
+
+	return nil
+}
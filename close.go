@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// contextCloser is implemented by services that require a context to be disposed.
+type contextCloser interface {
+	Close(ctx context.Context) error
+}
+
+// Close disposes of the services owned by this container layer and marks the container as closed.
+// A container owns the singleton services constructed by the factories registered to it, the
+// scoped services it has constructed, and the values registered via SetOwned. Values registered via
+// Set or WithValues and transient services are never disposed of by the container.
+//
+// Owned services implementing either Close(ctx) error or io.Closer are closed in the reverse order
+// in which they were constructed. Every service is closed even if another service fails to close,
+// and the resulting errors are returned together. Closing a container created via WithValues or
+// NewScope does not close its parent. Once closed, retrieving or registering services through the
+// container returns ErrClosed. A service whose factory returns after the container is closed is
+// closed immediately, and its retrieval returns ErrClosed. Closing a closed container has no effect.
+func (c *Container) Close(ctx context.Context) error {
+	c.mutex.Lock()
+	if c.closed.Load() {
+		c.mutex.Unlock()
+		return nil
+	}
+	owned := c.owned
+	c.owned = nil
+	c.closed.Store(true)
+	c.mutex.Unlock()
+
+	var errs []error
+	for i := len(owned) - 1; i >= 0; i-- {
+		if err := c.closeService(ctx, owned[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// closeService closes the given service if it implements either Close(ctx) error or io.Closer.
+func (c *Container) closeService(ctx context.Context, service lifecycleService) error {
+	var err error
+	switch closer := service.value.(type) {
+	case contextCloser:
+		_, err = c.callWithTimeout(ctx, closer.Close)
+	case io.Closer:
+		_, err = c.callWithTimeout(ctx, func(ctx context.Context) error { return closer.Close() })
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to close service %s: %w", prettyKey(service.key), err)
+	}
+
+	return nil
+}
+
+// own records the given value as constructed by this container so that it is disposed of when
+// the container is closed. If the container was closed while the value was being constructed, the
+// value is closed immediately and ErrClosed is returned.
+func (c *Container) own(ctx context.Context, key, value interface{}) error {
+	service := lifecycleService{key: key, value: value}
+
+	c.mutex.Lock()
+	closed := c.closed.Load()
+	if !closed {
+		c.owned = append(c.owned, service)
+	}
+	c.mutex.Unlock()
+
+	if !closed {
+		return nil
+	}
+
+	if err := c.closeService(ctx, service); err != nil {
+		return errors.Join(ErrClosed, err)
+	}
+
+	return ErrClosed
+}
+
+// isClosed returns true if Close has been called on this container.
+func (c *Container) isClosed() bool {
+	return c.closed.Load()
+}
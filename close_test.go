@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCloser struct {
+	name   string
+	events *[]string
+	err    error
+}
+
+func (c *testCloser) Close() error {
+	*c.events = append(*c.events, "close "+c.name)
+	return c.err
+}
+
+type testContextCloser struct {
+	testCloser
+}
+
+func (c *testContextCloser) Close(ctx context.Context) error {
+	return c.testCloser.Close()
+}
+
+func TestContainerClose(t *testing.T) {
+	var events []string
+	factory := func(name string) Factory {
+		return func(ctx context.Context, c *Container) (interface{}, error) {
+			return &testCloser{name: name, events: &events}, nil
+		}
+	}
+
+	container := New()
+	require.Nil(t, container.SetFactory("a", factory("a")))
+	require.Nil(t, container.SetFactory("b", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &testContextCloser{testCloser{name: "b", events: &events}}, nil
+	}))
+	require.Nil(t, container.SetFactory("unused", factory("unused")))
+	require.Nil(t, container.SetTransient("transient", factory("transient")))
+	require.Nil(t, container.Set("set", &testCloser{name: "set", events: &events}))
+	require.Nil(t, container.SetOwned("owned", &testCloser{name: "owned", events: &events}))
+
+	for _, key := range []string{"b", "a", "transient", "set"} {
+		_, err := container.Get(key)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, container.Close(context.Background()))
+	assert.Equal(t, []string{"close a", "close b", "close owned"}, events)
+
+	// Closing again has no effect
+	events = nil
+	require.Nil(t, container.Close(context.Background()))
+	assert.Empty(t, events)
+}
+
+func TestContainerCloseErrors(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.SetOwned("a", &testCloser{name: "a", events: &events, err: fmt.Errorf("oops a")}))
+	require.Nil(t, container.SetOwned("b", &testCloser{name: "b", events: &events, err: fmt.Errorf("oops b")}))
+
+	err := container.Close(context.Background())
+	assert.EqualError(t, err, "failed to close service \"b\": oops b\nfailed to close service \"a\": oops a")
+	assert.Equal(t, []string{"close b", "close a"}, events)
+}
+
+func TestContainerClosedGetAndSet(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", struct{}{}))
+	overlay := container.NewScope()
+	require.Nil(t, container.Close(context.Background()))
+
+	_, err := container.Get("a")
+	assert.True(t, errors.Is(err, ErrClosed))
+	_, err = container.Get("missing")
+	assert.True(t, errors.Is(err, ErrClosed))
+	assert.True(t, errors.Is(container.Set("b", struct{}{}), ErrClosed))
+	assert.True(t, errors.Is(Inject(context.Background(), container, &struct {
+		A struct{} `service:"a"`
+	}{}), ErrClosed))
+
+	// Overlays cannot reach services of a closed parent
+	_, err = overlay.Get("a")
+	assert.True(t, errors.Is(err, ErrClosed))
+	assert.True(t, errors.Is(overlay.Set("b", struct{}{}), ErrClosed))
+}
+
+func TestContainerCloseScope(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.SetFactory("singleton", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &testCloser{name: "singleton", events: &events}, nil
+	}))
+	require.Nil(t, container.SetScoped("scoped", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &testCloser{name: "scoped", events: &events}, nil
+	}))
+
+	scope, err := container.WithValues(map[interface{}]interface{}{
+		"value": &testCloser{name: "value", events: &events},
+	})
+	require.Nil(t, err)
+
+	for _, key := range []string{"singleton", "scoped", "value"} {
+		_, err := scope.Get(key)
+		require.Nil(t, err)
+	}
+
+	// Only the scoped instance belongs to the scope
+	require.Nil(t, scope.Close(context.Background()))
+	assert.Equal(t, []string{"close scoped"}, events)
+
+	_, err = container.Get("singleton")
+	require.Nil(t, err)
+
+	events = nil
+	require.Nil(t, container.Close(context.Background()))
+	assert.Equal(t, []string{"close singleton"}, events)
+}
+
+func TestContainerCloseDuringConstruction(t *testing.T) {
+	var events []string
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	container := New()
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		close(started)
+		<-release
+		return &testCloser{name: "a", events: &events}, nil
+	}))
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := container.Get("a")
+		errs <- err
+	}()
+
+	<-started
+	require.Nil(t, container.Close(context.Background()))
+	close(release)
+
+	// The service constructed after the container was closed is closed immediately
+	assert.True(t, errors.Is(<-errs, ErrClosed))
+	assert.Equal(t, []string{"close a"}, events)
+
+	require.Nil(t, container.Close(context.Background()))
+	assert.Equal(t, []string{"close a"}, events)
+}
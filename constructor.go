@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+var (
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	containerType = reflect.TypeOf((*Container)(nil))
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typeKey is the service key under which the result of a constructor registered via Provide
+// is stored.
+type typeKey struct{ t reflect.Type }
+
+// String returns the name of the key's type.
+func (k typeKey) String() string {
+	return k.t.String()
+}
+
+// TypeKey returns the service key under which Provide registers constructors that return a
+// value of the given type.
+func TypeKey(t reflect.Type) interface{} {
+	return typeKey{t}
+}
+
+// Provide registers the given constructor function as a singleton factory keyed by the type of
+// the constructor's first return value (see TypeKey). The constructor must return a single value,
+// optionally followed by an error. The constructor's parameters are resolved by type from the
+// container the first time the service is retrieved: a service registered via Provide for the
+// exact parameter type is used if one exists, otherwise the single service assignable to the
+// parameter type is used. Parameters of type context.Context and
+// *Container receive the context and the container passed to the factory.
+func (c *Container) Provide(constructor interface{}) error {
+	fn := reflect.ValueOf(constructor)
+	if err := checkFunc(fn, constructor); err != nil {
+		return err
+	}
+
+	ft := fn.Type()
+	if ft.NumOut() == 0 || ft.NumOut() > 2 || (ft.NumOut() == 2 && ft.Out(1) != errorType) {
+		return fmt.Errorf("constructor %s must return a value optionally followed by an error", ft)
+	}
+
+	factory := func(ctx context.Context, c *Container) (interface{}, error) {
+		results, err := call(ctx, c, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		return results[0].Interface(), nil
+	}
+
+	return c.set(&entry{
+		key:         TypeKey(ft.Out(0)),
+		factory:     factory,
+		lifetime:    Singleton,
+		typ:         ft.Out(0),
+		constructor: fn,
+	})
+}
+
+// Invoke calls the given function with its parameters resolved by type from the container (see
+// Provide). The function must return either nothing or a single error, which is returned from
+// this method.
+func (c *Container) Invoke(ctx context.Context, fn interface{}) error {
+	fv := reflect.ValueOf(fn)
+	if err := checkFunc(fv, fn); err != nil {
+		return err
+	}
+
+	ft := fv.Type()
+	if ft.NumOut() > 1 || (ft.NumOut() == 1 && ft.Out(0) != errorType) {
+		return fmt.Errorf("function %s must return nothing or an error", ft)
+	}
+
+	_, err := call(ctx, c, fv)
+	return err
+}
+
+// checkFunc returns an error if the given value is not a non-nil, non-variadic function. The raw
+// value is used to describe the value in the error message.
+func checkFunc(fn reflect.Value, raw interface{}) error {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return fmt.Errorf("expected a function, got %T", raw)
+	}
+
+	if fn.Type().IsVariadic() {
+		return fmt.Errorf("function %s must not be variadic", fn.Type())
+	}
+
+	return nil
+}
+
+// call invokes the given function with parameters resolved from the given container. If the
+// function's last return value is a non-nil error, that error is returned.
+func call(ctx context.Context, c *Container, fn reflect.Value) ([]reflect.Value, error) {
+	ft := fn.Type()
+	args := make([]reflect.Value, 0, ft.NumIn())
+	for i := 0; i < ft.NumIn(); i++ {
+		arg, err := c.resolveType(ctx, ft.In(i))
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	results := fn.Call(args)
+	if n := len(results); n > 0 && ft.Out(n-1) == errorType {
+		if err, _ := results[n-1].Interface().(error); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// resolveType returns a value of the given type from the container (see lookupType).
+func (c *Container) resolveType(ctx context.Context, t reflect.Type) (reflect.Value, error) {
+	switch t {
+	case contextType:
+		return reflect.ValueOf(&ctx).Elem(), nil
+	case containerType:
+		return reflect.ValueOf(c), nil
+	}
+
+	e, owner, ok, err := c.lookupType(t)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if !ok {
+		return reflect.Value{}, &NotFoundError{Type: t}
+	}
+
+	value, err := e.resolve(ctx, owner, c)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	if value == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(t) {
+		return reflect.Value{}, &TypeMismatchError{Key: e.key, Want: t, Got: v.Type()}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDB struct{ name string }
+type testStore struct {
+	db     *testDB
+	logger testLogger
+}
+
+type testLogger interface{ Log(string) }
+type testLoggerImpl struct{ prefix string }
+
+func (l *testLoggerImpl) Log(string) {}
+
+func newTestStore(db *testDB, logger testLogger) (*testStore, error) {
+	return &testStore{db, logger}, nil
+}
+
+func TestContainerProvide(t *testing.T) {
+	calls := 0
+	container := New()
+	require.Nil(t, container.Provide(newTestStore))
+	require.Nil(t, container.Provide(func() *testDB {
+		calls++
+		return &testDB{"main"}
+	}))
+	require.Nil(t, container.Provide(func() testLogger { return &testLoggerImpl{"app"} }))
+
+	value, err := container.Get(TypeKey(reflect.TypeOf(&testStore{})))
+	require.Nil(t, err)
+	assert.Equal(t, &testStore{&testDB{"main"}, &testLoggerImpl{"app"}}, value)
+
+	_, err = container.Get(TypeKey(reflect.TypeOf(&testStore{})))
+	require.Nil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestContainerProvideContextAndContainer(t *testing.T) {
+	type ctxKey struct{}
+
+	container := New()
+	require.Nil(t, container.Set("name", "main"))
+	require.Nil(t, container.Provide(func(ctx context.Context, c *Container) (*testDB, error) {
+		name, err := c.Get("name")
+		if err != nil {
+			return nil, err
+		}
+
+		return &testDB{ctx.Value(ctxKey{}).(string) + "-" + name.(string)}, nil
+	}))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "ctx")
+	value, err := container.GetContext(ctx, TypeKey(reflect.TypeOf(&testDB{})))
+	require.Nil(t, err)
+	assert.Equal(t, &testDB{"ctx-main"}, value)
+}
+
+func TestContainerProvideError(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Provide(func() (*testDB, error) { return nil, fmt.Errorf("oops") }))
+
+	_, err := container.Get(TypeKey(reflect.TypeOf(&testDB{})))
+	assert.EqualError(t, err, "failed to construct service *service.testDB: oops")
+}
+
+func TestContainerProvideMissingParameter(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Provide(newTestStore))
+
+	_, err := container.Get(TypeKey(reflect.TypeOf(&testStore{})))
+	assert.EqualError(t, err, "failed to construct service *service.testStore: no service registered assignable to type *service.testDB")
+}
+
+func TestContainerProvideDuplicate(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Provide(func() *testDB { return nil }))
+	assert.EqualError(t, container.Provide(func() (*testDB, error) { return nil, nil }), "duplicate service key *service.testDB")
+}
+
+func TestContainerProvideInvalidConstructor(t *testing.T) {
+	container := New()
+	assert.EqualError(t, container.Provide(nil), "expected a function, got <nil>")
+	assert.EqualError(t, container.Provide(42), "expected a function, got int")
+	assert.EqualError(t, container.Provide((func() int)(nil)), "expected a function, got func() int")
+	assert.EqualError(t, container.Provide(func() {}), "constructor func() must return a value optionally followed by an error")
+	assert.EqualError(t, container.Provide(func() (int, int) { return 0, 0 }), "constructor func() (int, int) must return a value optionally followed by an error")
+	assert.EqualError(t, container.Provide(func(...int) int { return 0 }), "function func(...int) int must not be variadic")
+}
+
+func TestContainerInvoke(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Provide(func() *testDB { return &testDB{"main"} }))
+	require.Nil(t, container.Provide(func() testLogger { return &testLoggerImpl{"app"} }))
+
+	var store *testStore
+	err := container.Invoke(context.Background(), func(db *testDB, logger testLogger) {
+		store = &testStore{db, logger}
+	})
+	require.Nil(t, err)
+	assert.Equal(t, &testStore{&testDB{"main"}, &testLoggerImpl{"app"}}, store)
+}
+
+func TestContainerInvokeError(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Provide(func() *testDB { return &testDB{"main"} }))
+
+	err := container.Invoke(context.Background(), func(db *testDB) error {
+		return fmt.Errorf("oops: %s", db.name)
+	})
+	assert.EqualError(t, err, "oops: main")
+
+	err = container.Invoke(context.Background(), func(logger testLogger) {})
+	assert.EqualError(t, err, "no service registered assignable to type service.testLogger")
+}
+
+func TestContainerInvokeInvalidFunction(t *testing.T) {
+	container := New()
+	assert.EqualError(t, container.Invoke(context.Background(), "foo"), "expected a function, got string")
+	assert.EqualError(t, container.Invoke(context.Background(), func() int { return 0 }), "function func() int must return nothing or an error")
+}
+
+func TestContainerProvideAssignableParameter(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("db", &testDB{"main"}))
+	require.Nil(t, container.Set("logger", &testLoggerImpl{"app"}))
+	require.Nil(t, container.Provide(newTestStore))
+
+	value, err := container.Get(TypeKey(reflect.TypeOf(&testStore{})))
+	require.Nil(t, err)
+	assert.Equal(t, &testStore{&testDB{"main"}, &testLoggerImpl{"app"}}, value)
+}
@@ -1,98 +1,432 @@
 package service
 
 import (
-	"fmt"
+	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 // Container is a collection of services retrievable by a unique service key value.
 type Container struct {
-	services  map[interface{}]interface{}
-	keysByTag map[string]interface{}
-	parent    *Container
-	mutex     sync.RWMutex
+	*registry
+
+	// resolution is the chain of services being constructed by the factory to which this container
+	// was given, if any (see Factory)
+	resolution *resolution
+}
+
+// registry holds the state of a container layer. The state is shared by the container and by the
+// containers given to the factories registered to it, which differ only in their resolution chain.
+type registry struct {
+	services   map[interface{}]*entry
+	keysByTag  map[string]interface{}
+	scoped     sync.Map // map[*entry]*instance
+	entries    []*entry
+	decorators map[interface{}][]Decorator
+	decorated  sync.Map // map[*entry]*decoration
+	parent     *Container
+	options    options
+	started    []lifecycleService
+	starting   bool
+	owned      []lifecycleService
+	closed     atomic.Bool
+	sealed     bool
+	snapshot   atomic.Pointer[snapshot]
+	watchers   map[*watcher]struct{}
+	mutex      sync.RWMutex
 }
 
 // New creates an empty service container.
-func New() *Container {
-	return &Container{
-		services:  map[interface{}]interface{}{},
-		keysByTag: map[string]interface{}{},
+func New(opts ...Option) *Container {
+	c := &Container{registry: &registry{
+		services:   map[interface{}]*entry{},
+		keysByTag:  map[string]interface{}{},
+		decorators: map[interface{}][]Decorator{},
+		watchers:   map[*watcher]struct{}{},
+	}}
+
+	for _, opt := range opts {
+		opt(&c.options)
 	}
+
+	return c
 }
 
 // Get retrieves the service registered to the given key. It is an error for a service not
-// to be registered to this key.
+// to be registered to this key. Services registered via SetFactory are constructed with a
+// background context; use GetContext to supply a different context to the factory.
 func (c *Container) Get(key interface{}) (interface{}, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext retrieves the service registered to the given key. If the service was registered
+// via SetFactory and has not yet been constructed, the given context is passed to its factory.
+func (c *Container) GetContext(ctx context.Context, key interface{}) (interface{}, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+
+	e, owner, ok := c.lookup(key)
+	if !ok {
+		return nil, &NotFoundError{Key: key}
+	}
+
+	return e.resolve(ctx, owner, c)
+}
+
+// lookup returns the entry registered to the given key along with the container layer that
+// holds the entry.
+func (c *Container) lookup(key interface{}) (*entry, *Container, bool) {
+	if e, ok := c.lookupLocal(key); ok {
+		return e, c, true
+	}
+
+	if c.parent != nil {
+		// Check parent layers
+		return c.parent.lookup(key)
+	}
+
+	return nil, nil, false
+}
+
+// lookupLocal returns the entry registered to the given key in this container layer, ignoring
+// any parent layers. The lock of a sealed container is not acquired (see Seal).
+func (c *Container) lookupLocal(key interface{}) (*entry, bool) {
+	if s := c.snapshot.Load(); s != nil {
+		return findEntry(s.services, s.keysByTag, key)
+	}
+
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
+	return c.localEntry(key)
+}
+
+// localEntry returns the entry registered to the given key in this container layer. This method
+// assumes that the container's lock is held.
+func (c *Container) localEntry(key interface{}) (*entry, bool) {
+	return findEntry(c.services, c.keysByTag, key)
+}
+
+// findEntry returns the entry registered to the given key in the given maps.
+func findEntry(services map[interface{}]*entry, keysByTag map[string]interface{}, key interface{}) (*entry, bool) {
 	// Service exists under key
-	if service, ok := c.services[key]; ok {
-		return service, nil
+	if e, ok := services[key]; ok {
+		return e, true
 	}
 	if tag, ok := tagForKey(key); ok {
-		if key, ok := c.keysByTag[tag]; ok {
+		if key, ok := keysByTag[tag]; ok {
 			// Service exists under key with same tag
-			if service, ok := c.services[key]; ok {
-				return service, nil
+			if e, ok := services[key]; ok {
+				return e, true
 			}
 		}
 	}
 
-	if c.parent != nil {
-		// Check parent layers
-		return c.parent.Get(key)
-	}
-
-	return nil, fmt.Errorf("no service registered to key %s", prettyKey(key))
+	return nil, false
 }
 
 // Set registers a service with the given key. It is an error for a service to already be
 // registered to this key (or a key with the same tag, see InjectableServiceKey).
 func (c *Container) Set(key, service interface{}) error {
+	return c.set(&entry{key: key, instance: instance{value: service, built: true}})
+}
+
+// SetOwned registers a service with the given key, as with Set, and transfers ownership of the
+// service to the container. The service is disposed of when the container is closed (see Close).
+func (c *Container) SetOwned(key, service interface{}) error {
+	return c.set(&entry{key: key, instance: instance{value: service, built: true}, owned: true})
+}
+
+// SetFactory registers a singleton factory with the given key. The factory is invoked the first
+// time the service is retrieved from the container (either via Get or Inject) and the resulting
+// value is returned for all subsequent retrievals. The same duplicate key rules as Set apply.
+func (c *Container) SetFactory(key interface{}, factory Factory) error {
+	return c.setFactory(key, factory, Singleton)
+}
+
+// SetTransient registers a factory with the given key that is invoked on every retrieval of
+// the service. The same duplicate key rules as Set apply.
+func (c *Container) SetTransient(key interface{}, factory Factory) error {
+	return c.setFactory(key, factory, Transient)
+}
+
+// SetScoped registers a factory with the given key that is invoked once per container from
+// which the service is retrieved. Containers created via NewScope or WithValues each construct
+// their own instance, and the factory receives that container so that it may depend on values
+// local to the scope. The same duplicate key rules as Set apply.
+func (c *Container) SetScoped(key interface{}, factory Factory) error {
+	return c.setFactory(key, factory, Scoped)
+}
+
+func (c *Container) setFactory(key interface{}, factory Factory, lifetime Lifetime) error {
+	return c.set(&entry{key: key, factory: factory, lifetime: lifetime})
+}
+
+// set registers the given entry with the root container.
+func (c *Container) set(e *entry) error {
+	root, err := c.setRoot(e)
+	if err != nil {
+		return err
+	}
+
+	root.notify(e.key)
+	return nil
+}
+
+// setRoot registers the given entry with the root container and returns the root container.
+func (c *Container) setRoot(e *entry) (*Container, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	if err := c.checkSet(e); err != nil {
+		return nil, err
+	}
+
+	if c.parent != nil {
+		// Delegate to parent if we're not the root
+		return c.parent.setRoot(e)
+	}
+
+	// We're the root, update both maps
+	c.store(e)
+	return c, nil
+}
+
+// SetLocal registers a service with the given key in this container layer only. Unlike Set, the
+// service is not visible to the parent of a container created via WithValues or NewScope, and may
+// shadow a service registered to a parent layer unless the container was created with the
+// ShadowDeny policy (see WithShadowPolicy). It is an error for a service to already be registered
+// to this key (or a key with the same tag) in this layer. On a root container, SetLocal is
+// equivalent to Set.
+func (c *Container) SetLocal(key, service interface{}) error {
+	return c.setLocal(&entry{key: key, instance: instance{value: service, built: true}})
+}
+
+// setLocal registers the given entry with this container layer.
+func (c *Container) setLocal(e *entry) error {
+	if c.parent != nil && c.options.shadowPolicy == ShadowDeny {
+		if existing, _, ok := c.parent.lookup(e.key); ok {
+			return &DuplicateKeyError{Key: e.key, ExistingKey: existing.key, Shadow: true}
+		}
+	}
+
+	c.mutex.Lock()
+	err := c.checkSet(e)
+	if err == nil {
+		c.store(e)
+	}
+	c.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	c.notify(e.key)
+	return nil
+}
+
+// checkSet returns an error if the given entry cannot be registered to this container layer. This
+// method assumes that the container's lock is held.
+func (c *Container) checkSet(e *entry) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if c.sealed {
+		return ErrSealed
+	}
+
 	// Service exists under key
-	if _, ok := c.services[key]; ok {
-		return fmt.Errorf(`duplicate service key %s`, prettyKey(key))
+	if _, ok := c.services[e.key]; ok {
+		return &DuplicateKeyError{Key: e.key, ExistingKey: e.key}
 	}
 
-	tag, ok := tagForKey(key)
-	if ok {
+	if tag, ok := tagForKey(e.key); ok {
 		// Service exists under key with same tag
-		if _, ok := c.keysByTag[tag]; ok {
-			return fmt.Errorf(`duplicate service key %s`, prettyKey(key))
+		if existingKey, ok := c.keysByTag[tag]; ok {
+			return &DuplicateKeyError{Key: e.key, ExistingKey: existingKey}
 		}
 	}
 
-	if c.parent != nil {
-		// Delegate to parent if we're not the root
-		return c.parent.Set(key, service)
+	return nil
+}
+
+// store adds the given entry to this container layer. This method assumes that the container's
+// lock is held.
+func (c *Container) store(e *entry) {
+	c.services[e.key] = e
+	c.entries = append(c.entries, e)
+	if tag, ok := tagForKey(e.key); ok {
+		c.keysByTag[tag] = e.key
+	}
+	if e.owned {
+		c.owned = append(c.owned, lifecycleService{key: e.key, value: e.instance.value})
 	}
+}
 
-	// We're the root, update both maps
-	c.services[key] = service
-	if ok {
-		c.keysByTag[tag] = key
+// Replace registers a service with the given key in place of the service registered to the key (or
+// a key with the same tag, see InjectableServiceKey). The service is replaced in the container layer
+// that holds it: replacing a service registered to a parent layer modifies the parent, as with Set,
+// while replacing a service registered via SetLocal modifies only that layer. The new service keeps
+// the registration order and group membership of the service it replaces (see AddToGroup). Services
+// that were already constructed from the replaced service are not affected. It is an error for no
+// service to be registered to this key.
+func (c *Container) Replace(key, service interface{}) error {
+	return c.update(key, func(layer *Container, old *entry) {
+		layer.replace(old, &entry{key: key, instance: instance{value: service, built: true}, group: old.group})
+	})
+}
+
+// Remove unregisters the service registered to the given key (or a key with the same tag, see
+// InjectableServiceKey). As with Replace, the service is removed from the container layer that holds
+// it. If the removed service was registered via SetLocal, a service registered to a parent layer with
+// the same key becomes visible again. Removing a service does not close it (see Close). It is an error
+// for no service to be registered to this key.
+func (c *Container) Remove(key interface{}) error {
+	return c.update(key, func(layer *Container, old *entry) {
+		layer.unstore(old)
+	})
+}
+
+// update calls the given function with the container layer holding the service registered to the
+// given key and the service's entry. The function is called while the layer's lock is held.
+func (c *Container) update(key interface{}, f func(layer *Container, old *entry)) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	_, layer, ok := c.lookup(key)
+	if !ok {
+		return &NotFoundError{Key: key}
+	}
+
+	for l := c; l != layer; l = l.parent {
+		if l.isSealed() {
+			// Do not modify parent layers through a sealed overlay
+			return ErrSealed
+		}
+	}
+
+	if err := layer.updateLocal(key, f); err != nil {
+		return err
+	}
+
+	layer.notify(key)
+	return nil
+}
+
+// updateLocal calls the given function with this container layer and the entry registered to the
+// given key in this layer while the layer's lock is held.
+func (c *Container) updateLocal(key interface{}, f func(layer *Container, old *entry)) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if c.sealed {
+		return ErrSealed
 	}
 
+	old, ok := c.localEntry(key)
+	if !ok {
+		// Removed concurrently
+		return &NotFoundError{Key: key}
+	}
+
+	f(c, old)
 	return nil
 }
 
+// replace substitutes the given entry for an entry of this container layer in place. This method
+// assumes that the container's lock is held.
+func (c *Container) replace(old, e *entry) {
+	for i, existing := range c.entries {
+		if existing == old {
+			c.entries[i] = e
+			break
+		}
+	}
+
+	delete(c.services, old.key)
+	c.decorated.Delete(old)
+	if tag, ok := tagForKey(old.key); ok {
+		delete(c.keysByTag, tag)
+	}
+
+	c.services[e.key] = e
+	if tag, ok := tagForKey(e.key); ok {
+		c.keysByTag[tag] = e.key
+	}
+}
+
+// unstore removes the given entry from this container layer. This method assumes that the
+// container's lock is held.
+func (c *Container) unstore(e *entry) {
+	delete(c.services, e.key)
+	c.decorated.Delete(e)
+	if tag, ok := tagForKey(e.key); ok {
+		delete(c.keysByTag, tag)
+	}
+
+	for i, existing := range c.entries {
+		if existing == e {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			break
+		}
+	}
+}
+
+// scopedInstance returns the instance of the given scoped entry local to this container. The
+// container's lock is not acquired.
+func (c *Container) scopedInstance(e *entry) *instance {
+	if i, ok := c.scoped.Load(e); ok {
+		return i.(*instance)
+	}
+
+	i, _ := c.scoped.LoadOrStore(e, &instance{})
+	return i.(*instance)
+}
+
+// NewScope returns an empty container layered on top of this one. Services registered via
+// SetScoped are constructed once for the new container. Calling Set on the resulting container
+// will modify the original container (see WithValues); use SetLocal to register services visible
+// only to the new container. The new container inherits the options of this container, and the
+// given options are applied on top.
+func (c *Container) NewScope(opts ...Option) *Container {
+	c2 := New()
+	c2.parent = c
+	c2.options = c.options
+	for _, opt := range opts {
+		opt(&c2.options)
+	}
+
+	return c2
+}
+
 // WithValues returns a copy of the container with the given service map overlaid on top.
 // Calling  Set on the resulting container will modify the original container and any other
-// containers created from this method. It is an error for the given map to contain two keys
-// that resolve to the same tag (see InjectableServiceKey).
+// containers created from this method; use SetLocal to modify only the resulting container. It
+// is an error for the given map to contain two keys that resolve to the same tag (see
+// InjectableServiceKey). The resulting container is also a new scope for services registered
+// via SetScoped. The values are registered in a stable order determined by their keys, so that
+// the registration order reported by Keys, Entries, and the graph exporters does not vary.
 func (c *Container) WithValues(services map[interface{}]interface{}) (*Container, error) {
+	keys := make([]interface{}, 0, len(services))
+	for k := range services {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return describeKey(keys[i]) < describeKey(keys[j]) })
+
 	c2 := New()
-	for k, v := range services {
-		if err := c2.Set(k, v); err != nil {
+	for _, k := range keys {
+		if err := c2.Set(k, services[k]); err != nil {
 			return nil, err
 		}
 	}
 
 	c2.parent = c
+	c2.options = c.options
 	return c2, nil
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -199,3 +200,162 @@ func assertValue(t *testing.T, container *Container, key, expected interface{})
 	require.Nil(t, err)
 	assert.Equal(t, expected, value)
 }
+
+func TestContainerSetLocal(t *testing.T) {
+	type T struct{ val int }
+
+	container1 := New()
+	container1.Set("a", &T{10})
+
+	container2, err := container1.WithValues(map[interface{}]interface{}{"b": &T{20}})
+	require.Nil(t, err)
+	container3 := container1.NewScope()
+
+	require.Nil(t, container2.SetLocal("a", &T{25}))
+	require.Nil(t, container2.SetLocal("c", &T{30}))
+	require.Nil(t, container3.SetLocal(testKey1{"c"}, &T{35}))
+
+	assertValue(t, container1, "a", &T{10})
+	assertValue(t, container2, "a", &T{25})
+	assertValue(t, container2, "b", &T{20})
+	assertValue(t, container2, "c", &T{30})
+	assertValue(t, container3, "a", &T{10})
+	assertValue(t, container3, "c", &T{35})
+
+	_, err = container1.Get("c")
+	assert.EqualError(t, err, `no service registered to key "c"`)
+
+	// Parent registrations are visible, but do not clash with local keys
+	require.Nil(t, container1.Set("c", &T{40}))
+	assertValue(t, container1, "c", &T{40})
+	assertValue(t, container2, "c", &T{30})
+}
+
+func TestContainerSetLocalDuplicate(t *testing.T) {
+	container, err := New().WithValues(map[interface{}]interface{}{"dup": struct{}{}})
+	require.Nil(t, err)
+
+	assert.EqualError(t, container.SetLocal("dup", struct{}{}), `duplicate service key "dup"`)
+	require.Nil(t, container.SetLocal(testKey1{"foo"}, struct{}{}))
+	assert.EqualError(t, container.SetLocal(testKey2{"foo"}, struct{}{}), `duplicate service key testKey2 ("foo")`)
+	assert.EqualError(t, container.Set("foo", struct{}{}), `duplicate service key "foo"`)
+}
+
+func TestContainerSetLocalRoot(t *testing.T) {
+	container := New()
+	require.Nil(t, container.SetLocal("a", 10))
+	assertValue(t, container, "a", 10)
+	assertValue(t, container.NewScope(), "a", 10)
+}
+
+func TestContainerSetLocalShadowPolicy(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set(testKey1{"a"}, 10))
+
+	scope := container.NewScope(WithShadowPolicy(ShadowDeny))
+	assert.EqualError(t, scope.SetLocal("a", 20), `service key "a" would shadow a service registered to a parent container`)
+	require.Nil(t, scope.SetLocal("b", 20))
+	assertValue(t, scope, "a", 10)
+	assertValue(t, scope, "b", 20)
+
+	// The policy is inherited by nested scopes
+	nested := scope.NewScope()
+	assert.EqualError(t, nested.SetLocal("b", 30), `service key "b" would shadow a service registered to a parent container`)
+	require.Nil(t, nested.NewScope(WithShadowPolicy(ShadowAllow)).SetLocal("b", 30))
+
+	// Overlay values are not subject to the policy
+	overlay, err := New(WithShadowPolicy(ShadowDeny)).WithValues(map[interface{}]interface{}{"a": 10})
+	require.Nil(t, err)
+	_, err = overlay.WithValues(map[interface{}]interface{}{"a": 20})
+	require.Nil(t, err)
+}
+
+func TestContainerReplace(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+	require.Nil(t, container.Set(testKey1{"b"}, &TI{20}))
+	require.Nil(t, container.Set("c", &TI{30}))
+
+	require.Nil(t, container.Replace("a", &TI{15}))
+	assertValue(t, container, "a", &TI{15})
+
+	// Tag-equivalent keys replace the existing registration
+	require.Nil(t, container.Replace(testKey2{"b"}, &TI{25}))
+	assertValue(t, container, "b", &TI{25})
+	assertValue(t, container, testKey1{"b"}, &TI{25})
+	assert.Equal(t, []interface{}{"a", testKey2{"b"}, "c"}, container.Graph().Keys())
+
+	err := container.Replace("d", &TI{40})
+	assert.EqualError(t, err, `no service registered to key "d"`)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestContainerReplaceGroupMember(t *testing.T) {
+	container := New()
+	require.Nil(t, container.AddToGroup("health", "db", &testCheckerImpl{"db"}))
+	require.Nil(t, container.Replace("db", &testCheckerImpl{"replaced"}))
+
+	members := container.groupMembers("health")
+	require.Len(t, members, 1)
+	assert.Equal(t, &testCheckerImpl{"replaced"}, members[0].e.instance.value)
+}
+
+func TestContainerRemove(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set(testKey1{"a"}, &TI{10}))
+	require.Nil(t, container.Set("b", &TI{20}))
+
+	require.Nil(t, container.Remove(testKey2{"a"}))
+	_, err := container.Get(testKey1{"a"})
+	assert.EqualError(t, err, `no service registered to key testKey1 ("a")`)
+	assert.Equal(t, []interface{}{"b"}, container.Graph().Keys())
+
+	// The key may be registered again
+	require.Nil(t, container.Set("a", &TI{15}))
+	assertValue(t, container, "a", &TI{15})
+
+	err = container.Remove("c")
+	assert.EqualError(t, err, `no service registered to key "c"`)
+}
+
+func TestContainerReplaceAndRemoveLayers(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+	require.Nil(t, container.Set("b", &TI{20}))
+
+	overlay, err := container.WithValues(map[interface{}]interface{}{"local": &TI{30}})
+	require.Nil(t, err)
+	require.Nil(t, overlay.SetLocal("b", &TI{25}))
+
+	// Services of parent layers are modified in the parent
+	require.Nil(t, overlay.Replace("a", &TI{15}))
+	assertValue(t, container, "a", &TI{15})
+	assertValue(t, overlay, "a", &TI{15})
+
+	// Local services are modified only in the overlay
+	require.Nil(t, overlay.Replace("b", &TI{26}))
+	assertValue(t, container, "b", &TI{20})
+	assertValue(t, overlay, "b", &TI{26})
+
+	require.Nil(t, overlay.Remove("local"))
+	_, err = overlay.Get("local")
+	assert.NotNil(t, err)
+
+	// Removing a local service reveals the shadowed service
+	require.Nil(t, overlay.Remove("b"))
+	assertValue(t, overlay, "b", &TI{20})
+
+	require.Nil(t, overlay.Remove("b"))
+	_, err = container.Get("b")
+	assert.NotNil(t, err)
+}
+
+func TestContainerReplaceDecorated(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", "db"))
+	require.Nil(t, container.Decorate("a", wrapWith("metrics")))
+	assertValue(t, container, "a", "metrics(db)")
+
+	require.Nil(t, container.Replace("a", "cache"))
+	assertValue(t, container, "a", "metrics(cache)")
+}
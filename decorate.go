@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decorator wraps a service when it is retrieved from a container. The given service is the
+// original value, or the value returned by the previous decorator of the same service.
+type Decorator func(ctx context.Context, service interface{}) (interface{}, error)
+
+// Decorate registers a decorator for the service registered to the given key. The values returned
+// by Get, GetContext, and Inject are wrapped by the decorators of the service, which compose in the
+// order in which they were registered. Decorators registered to a container created via WithValues
+// or NewScope apply only to services retrieved through that container. Decorators apply only to a
+// service registered to the same layer or a parent layer; a service registered via SetLocal to a
+// nearer layer is not wrapped by the decorators of the service it shadows. Decorated values of
+// singleton services are computed once, and decorated values of transient services are computed
+// on every retrieval. It is an error for no service to be registered to the given key.
+func (c *Container) Decorate(key interface{}, decorator Decorator) error {
+	e, _, ok := c.lookup(key)
+	if !ok {
+		return &NotFoundError{Key: key}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if c.sealed {
+		return ErrSealed
+	}
+
+	decoratorKey := canonicalKey(e.key)
+	c.decorators[decoratorKey] = append(c.decorators[decoratorKey], decorator)
+	return nil
+}
+
+// decoration is a decorated service value cached by a container layer. The count is the number of
+// decorators that were applied to construct the value.
+type decoration struct {
+	count int
+	value interface{}
+}
+
+// canonicalKey returns the tag of the given key if it has one, and the key itself otherwise. Keys
+// with the same tag are considered equivalent (see InjectableServiceKey).
+func canonicalKey(key interface{}) interface{} {
+	if tag, ok := tagForKey(key); ok {
+		return tag
+	}
+
+	return key
+}
+
+// decorate applies the decorators registered for the entry to the given value. Decorators are
+// collected from each layer between the owner and the origin, starting with the owner. The result
+// is cached by the nearest layer that registered a decorator for the entry, or by the origin if the
+// entry is scoped, as that layer determines the final value.
+func (e *entry) decorate(ctx context.Context, value interface{}, owner, origin *Container) (interface{}, error) {
+	var (
+		decorators []Decorator
+		cacheLayer *Container
+	)
+	for layer := origin; layer != owner.parent; layer = layer.parent {
+		layerDecorators := layer.decoratorsFor(e)
+		if len(layerDecorators) == 0 {
+			continue
+		}
+
+		if cacheLayer == nil {
+			cacheLayer = layer
+		}
+
+		// Layers nearer to the root apply their decorators first
+		decorators = append(append([]Decorator(nil), layerDecorators...), decorators...)
+	}
+	if len(decorators) == 0 {
+		return value, nil
+	}
+
+	if e.factory != nil {
+		switch e.lifetime {
+		case Transient:
+			cacheLayer = nil
+		case Scoped:
+			cacheLayer = origin
+		}
+	}
+
+	if cacheLayer != nil {
+		if cached, ok := cacheLayer.decoration(e, len(decorators)); ok {
+			return cached, nil
+		}
+	}
+
+	for _, decorator := range decorators {
+		decorated, err := decorator(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decorate service %s: %w", prettyKey(e.key), err)
+		}
+
+		value = decorated
+	}
+
+	if cacheLayer != nil {
+		return cacheLayer.storeDecoration(e, len(decorators), value), nil
+	}
+
+	return value, nil
+}
+
+// decoratorsFor returns the decorators registered to this container layer for the given entry. The
+// lock of a sealed container is not acquired (see Seal).
+func (c *Container) decoratorsFor(e *entry) []Decorator {
+	if s := c.snapshot.Load(); s != nil {
+		return s.decorators[canonicalKey(e.key)]
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.decorators[canonicalKey(e.key)]
+}
+
+// decoration returns the cached decorated value of the given entry if it was constructed with the
+// given number of decorators. The container's lock is not acquired.
+func (c *Container) decoration(e *entry, count int) (interface{}, bool) {
+	if d, ok := c.decorated.Load(e); ok && d.(*decoration).count == count {
+		return d.(*decoration).value, true
+	}
+
+	return nil, false
+}
+
+// storeDecoration caches the decorated value of the given entry and returns the cached value. If
+// another value constructed with the same number of decorators was cached concurrently, that value
+// is returned instead so that every caller observes the same decorated service.
+func (c *Container) storeDecoration(e *entry, count int, value interface{}) interface{} {
+	d := &decoration{count: count, value: value}
+	for {
+		existing, loaded := c.decorated.LoadOrStore(e, d)
+		if !loaded {
+			return value
+		}
+		if existing := existing.(*decoration); existing.count == count {
+			return existing.value
+		}
+		if c.decorated.CompareAndSwap(e, existing, d) {
+			return value
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func wrapWith(name string) Decorator {
+	return func(ctx context.Context, service interface{}) (interface{}, error) {
+		return fmt.Sprintf("%s(%v)", name, service), nil
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	type T struct {
+		Value string `service:"value"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", "db"))
+	require.Nil(t, container.Decorate("value", wrapWith("metrics")))
+	require.Nil(t, container.Decorate("value", wrapWith("logging")))
+
+	value, err := container.Get("value")
+	require.Nil(t, err)
+	assert.Equal(t, "logging(metrics(db))", value)
+
+	obj := &T{}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	assert.Equal(t, "logging(metrics(db))", obj.Value)
+}
+
+func TestDecorateMissing(t *testing.T) {
+	err := New().Decorate("value", wrapWith("metrics"))
+	assert.EqualError(t, err, `no service registered to key "value"`)
+}
+
+func TestDecorateSingleton(t *testing.T) {
+	container := New()
+	require.Nil(t, container.SetFactory("value", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{42}, nil
+	}))
+
+	calls := 0
+	require.Nil(t, container.Decorate("value", func(ctx context.Context, service interface{}) (interface{}, error) {
+		calls++
+		return &TI{service.(*TI).val + 1}, nil
+	}))
+
+	value1, err := container.Get("value")
+	require.Nil(t, err)
+	value2, err := container.NewScope().Get("value")
+	require.Nil(t, err)
+	assert.Same(t, value1, value2)
+	assert.Equal(t, 43, value1.(*TI).val)
+	assert.Equal(t, 1, calls)
+
+	// Adding a decorator recomputes the decorated value
+	require.Nil(t, container.Decorate("value", wrapWith("logging")))
+	value, err := container.Get("value")
+	require.Nil(t, err)
+	assert.Equal(t, "logging(&{43})", value)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDecorateTransient(t *testing.T) {
+	container := New()
+	require.Nil(t, container.SetTransient("value", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{42}, nil
+	}))
+	require.Nil(t, container.Decorate("value", func(ctx context.Context, service interface{}) (interface{}, error) {
+		return &TI{service.(*TI).val + 1}, nil
+	}))
+
+	value1, err := container.Get("value")
+	require.Nil(t, err)
+	value2, err := container.Get("value")
+	require.Nil(t, err)
+	assert.NotSame(t, value1, value2)
+	assert.Equal(t, 43, value2.(*TI).val)
+}
+
+func TestDecorateOverlay(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", "db"))
+	require.Nil(t, container.Set(testKey1{"other"}, "other"))
+	require.Nil(t, container.Decorate("value", wrapWith("metrics")))
+
+	overlay, err := container.WithValues(map[interface{}]interface{}{"local": "local"})
+	require.Nil(t, err)
+	require.Nil(t, overlay.Decorate("value", wrapWith("logging")))
+	require.Nil(t, overlay.Decorate(testKey2{"other"}, wrapWith("logging")))
+
+	value, err := overlay.Get("value")
+	require.Nil(t, err)
+	assert.Equal(t, "logging(metrics(db))", value)
+
+	value, err = overlay.Get("other")
+	require.Nil(t, err)
+	assert.Equal(t, "logging(other)", value)
+
+	value, err = container.Get("value")
+	require.Nil(t, err)
+	assert.Equal(t, "metrics(db)", value)
+
+	value, err = container.Get("other")
+	require.Nil(t, err)
+	assert.Equal(t, "other", value)
+}
+
+func TestDecorateShadowed(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", "db"))
+	require.Nil(t, container.Decorate("value", wrapWith("metrics")))
+
+	scope := container.NewScope()
+	require.Nil(t, scope.SetLocal("value", "local"))
+
+	value, err := scope.Get("value")
+	require.Nil(t, err)
+	assert.Equal(t, "local", value)
+
+	require.Nil(t, scope.Decorate("value", wrapWith("logging")))
+	value, err = scope.Get("value")
+	require.Nil(t, err)
+	assert.Equal(t, "logging(local)", value)
+}
+
+func TestDecorateScoped(t *testing.T) {
+	container := New()
+	require.Nil(t, container.SetScoped("value", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{}, nil
+	}))
+	require.Nil(t, container.Decorate("value", func(ctx context.Context, service interface{}) (interface{}, error) {
+		return []*TI{service.(*TI)}, nil
+	}))
+
+	scope1 := container.NewScope()
+	scope2 := container.NewScope()
+
+	value1, err := scope1.Get("value")
+	require.Nil(t, err)
+	value2, err := scope2.Get("value")
+	require.Nil(t, err)
+	assert.NotSame(t, value1.([]*TI)[0], value2.([]*TI)[0])
+
+	value, err := scope1.Get("value")
+	require.Nil(t, err)
+	assert.Same(t, value1.([]*TI)[0], value.([]*TI)[0])
+}
+
+func TestDecorateError(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", "db"))
+	require.Nil(t, container.Decorate("value", func(ctx context.Context, service interface{}) (interface{}, error) {
+		return nil, errors.New("oops")
+	}))
+
+	_, err := container.Get("value")
+	assert.EqualError(t, err, `failed to decorate service "value": oops`)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrNotFound matches errors returned when no service is registered to a key or type.
+	ErrNotFound = errors.New("service not found")
+
+	// ErrDuplicateKey matches errors returned when a service key conflicts with an existing
+	// registration.
+	ErrDuplicateKey = errors.New("duplicate service key")
+
+	// ErrTypeMismatch matches errors returned when a service does not have the expected type.
+	ErrTypeMismatch = errors.New("service type mismatch")
+
+	// ErrInvalidTag matches errors returned when a struct field has a malformed tag.
+	ErrInvalidTag = errors.New("invalid struct tag")
+
+	// ErrAmbiguousType matches errors returned when more than one service matches a type.
+	ErrAmbiguousType = errors.New("ambiguous service type")
+
+	// ErrCycle matches errors returned when a service depends on itself.
+	ErrCycle = errors.New("dependency cycle")
+
+	// ErrClosed is returned when retrieving or registering services on a closed container.
+	ErrClosed = errors.New("service container is closed")
+
+	// ErrSealed is returned when registering, replacing, or removing services on a sealed container.
+	ErrSealed = errors.New("service container is sealed")
+)
+
+// NotFoundError is returned when no service is registered to a key. When a service is resolved
+// by type rather than by key, Type is set instead of Key.
+type NotFoundError struct {
+	Key  interface{}
+	Type reflect.Type
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Type != nil {
+		return fmt.Sprintf("no service registered assignable to type %s", e.Type)
+	}
+
+	return fmt.Sprintf("no service registered to key %s", prettyKey(e.Key))
+}
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// DuplicateKeyError is returned when registering a service to a key that is equivalent to the key
+// of an existing service (see InjectableServiceKey). Shadow is set when the existing service is
+// registered to a parent layer and the container's shadow policy forbids hiding it (see
+// WithShadowPolicy).
+type DuplicateKeyError struct {
+	Key         interface{}
+	ExistingKey interface{}
+	Shadow      bool
+}
+
+func (e *DuplicateKeyError) Error() string {
+	if e.Shadow {
+		return fmt.Sprintf("service key %s would shadow a service registered to a parent container", prettyKey(e.Key))
+	}
+
+	return fmt.Sprintf("duplicate service key %s", prettyKey(e.Key))
+}
+
+func (e *DuplicateKeyError) Is(target error) bool { return target == ErrDuplicateKey }
+
+// TypeMismatchError is returned when a service cannot be used as a value of the wanted type. Field
+// is the name of the struct field being injected, if any. Got is nil if the service is nil. Strict
+// is set if the field required an assignable type rather than a convertible one (see WithStrictTypes).
+type TypeMismatchError struct {
+	Key    interface{}
+	Field  string
+	Want   reflect.Type
+	Got    reflect.Type
+	Strict bool
+}
+
+func (e *TypeMismatchError) Error() string {
+	if e.Field != "" && e.Strict {
+		return fmt.Sprintf("field '%s' requires a value assignable to type %s, but service %s has type %s", e.Field, e.Want, prettyKey(e.Key), typeName(e.Got))
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("field '%s' cannot be assigned a value of type %s", e.Field, typeName(e.Got))
+	}
+
+	return fmt.Sprintf("service %s has type %s, expected %s", prettyKey(e.Key), typeName(e.Got), e.Want)
+}
+
+func (e *TypeMismatchError) Is(target error) bool { return target == ErrTypeMismatch }
+
+// InvalidTagError is returned when a struct field has a malformed tag. Tag is the name of the
+// malformed tag (e.g. "service" or "optional"). Reason describes the problem, if known.
+type InvalidTagError struct {
+	Field  string
+	Tag    string
+	Reason string
+}
+
+func (e *InvalidTagError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("field '%s' has an invalid %s tag: %s", e.Field, e.Tag, e.Reason)
+	}
+
+	return fmt.Sprintf("field '%s' has an invalid %s tag", e.Field, e.Tag)
+}
+
+func (e *InvalidTagError) Is(target error) bool { return target == ErrInvalidTag }
+
+// AmbiguousTypeError is returned when a service is resolved by type and more than one service is
+// assignable to that type. Keys lists the key of each matching service.
+type AmbiguousTypeError struct {
+	Type reflect.Type
+	Keys []interface{}
+}
+
+func (e *AmbiguousTypeError) Error() string {
+	return fmt.Sprintf("ambiguous service type %s: matched keys %s", e.Type, joinKeys(e.Keys, ", "))
+}
+
+func (e *AmbiguousTypeError) Is(target error) bool { return target == ErrAmbiguousType }
+
+// CycleError is returned when a service depends on itself, either directly or transitively.
+// The path begins and ends with the same service key.
+type CycleError struct {
+	Path []interface{}
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", joinKeys(e.Path, " -> "))
+}
+
+func (e *CycleError) Is(target error) bool { return target == ErrCycle }
+
+// joinKeys returns the human-readable names of the given keys joined by the given separator.
+func joinKeys(keys []interface{}, sep string) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, prettyKey(key))
+	}
+
+	return strings.Join(names, sep)
+}
+
+// typeName returns the name of the given type, or "nil" for a nil type.
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "nil"
+	}
+
+	return t.String()
+}
+
+// FieldError describes a struct field that could not be injected. Path is the dotted path to the
+// field from the injected value (e.g. "Server.Handlers.Logger"). Key is the service key requested
+// by the field, or the result of TypeKey for fields injected by type. Key is nil if the field's
+// tags are malformed.
+type FieldError struct {
+	Path string
+	Key  interface{}
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if e.Key == nil {
+		return fmt.Sprintf("%s: %s", e.Path, e.Err)
+	}
+
+	return fmt.Sprintf("%s (%s): %s", e.Path, prettyKey(e.Key), e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// InjectionError is returned by InjectAll when one or more fields could not be injected.
+type InjectionError struct {
+	Errors []*FieldError
+}
+
+func (e *InjectionError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, "\n\t"+err.Error())
+	}
+
+	noun := "fields"
+	if len(e.Errors) == 1 {
+		noun = "field"
+	}
+
+	return fmt.Sprintf("%d %s could not be injected:%s", len(e.Errors), noun, strings.Join(messages, ""))
+}
+
+func (e *InjectionError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
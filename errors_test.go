@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotFoundError(t *testing.T) {
+	container := New()
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.GetContext(ctx, "missing")
+	}))
+
+	_, err := container.Get("a")
+	assert.EqualError(t, err, `failed to construct service "a": no service registered to key "missing"`)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	var notFoundErr *NotFoundError
+	require.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, "missing", notFoundErr.Key)
+
+	err = Inject(context.Background(), container, &struct {
+		Value *TI `service:",type"`
+	}{})
+	require.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, reflect.TypeOf(&TI{}), notFoundErr.Type)
+}
+
+func TestDuplicateKeyError(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set(testKey1{"dup"}, struct{}{}))
+
+	err := container.Set(testKey2{"dup"}, struct{}{})
+	assert.True(t, errors.Is(err, ErrDuplicateKey))
+
+	var duplicateKeyErr *DuplicateKeyError
+	require.True(t, errors.As(err, &duplicateKeyErr))
+	assert.Equal(t, testKey2{"dup"}, duplicateKeyErr.Key)
+	assert.Equal(t, testKey1{"dup"}, duplicateKeyErr.ExistingKey)
+	assert.False(t, duplicateKeyErr.Shadow)
+
+	err = container.NewScope(WithShadowPolicy(ShadowDeny)).SetLocal("dup", struct{}{})
+	require.True(t, errors.As(err, &duplicateKeyErr))
+	assert.Equal(t, "dup", duplicateKeyErr.Key)
+	assert.Equal(t, testKey1{"dup"}, duplicateKeyErr.ExistingKey)
+	assert.True(t, duplicateKeyErr.Shadow)
+}
+
+func TestTypeMismatchError(t *testing.T) {
+	type T struct {
+		Value *TI `service:"value"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TF{3.14}))
+
+	err := Inject(context.Background(), container, &T{})
+	assert.EqualError(t, err, "field 'Value' cannot be assigned a value of type *service.TF")
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+
+	var typeMismatchErr *TypeMismatchError
+	require.True(t, errors.As(err, &typeMismatchErr))
+	assert.Equal(t, &TypeMismatchError{
+		Key:   "value",
+		Field: "Value",
+		Want:  reflect.TypeOf(&TI{}),
+		Got:   reflect.TypeOf(&TF{}),
+	}, typeMismatchErr)
+
+	_, err = Get(container, Key[*TI]("value"))
+	require.True(t, errors.As(err, &typeMismatchErr))
+	assert.Equal(t, "", typeMismatchErr.Field)
+}
+
+func TestInvalidTagError(t *testing.T) {
+	type T struct {
+		Value *TI `service:"value" optional:"yup"`
+	}
+
+	err := Inject(context.Background(), New(), &T{})
+	assert.True(t, errors.Is(err, ErrInvalidTag))
+
+	var invalidTagErr *InvalidTagError
+	require.True(t, errors.As(err, &invalidTagErr))
+	assert.Equal(t, &InvalidTagError{Field: "Value", Tag: "optional"}, invalidTagErr)
+}
+
+func TestAmbiguousTypeError(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", &TI{1}))
+	require.Nil(t, container.Set("b", &TI{2}))
+
+	err := container.Invoke(context.Background(), func(*TI) {})
+	assert.True(t, errors.Is(err, ErrAmbiguousType))
+
+	var ambiguousTypeErr *AmbiguousTypeError
+	require.True(t, errors.As(err, &ambiguousTypeErr))
+	assert.Equal(t, []interface{}{"a", "b"}, ambiguousTypeErr.Keys)
+}
+
+func TestCycleErrorIs(t *testing.T) {
+	err := &CycleError{Path: []interface{}{"a", "a"}}
+	assert.True(t, errors.Is(err, ErrCycle))
+	assert.False(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Factory constructs a service on first use. The given container should be used to retrieve
+// any services on which the constructed service depends. Services retrieved through the given
+// container are recorded as dependencies of the constructed service (see Container.Graph), and a
+// service that depends on itself through that container fails with a CycleError. Services
+// retrieved through any other container, including a container retained from elsewhere, are not
+// tracked.
+type Factory func(ctx context.Context, c *Container) (interface{}, error)
+
+// Lifetime controls how often a service registered with a factory is constructed.
+type Lifetime int
+
+const (
+	// Singleton services are constructed once and shared by the container in which they are
+	// registered and every container layered on top of it.
+	Singleton Lifetime = iota
+
+	// Transient services are constructed on every retrieval.
+	Transient
+
+	// Scoped services are constructed once per container from which they are retrieved. Each
+	// container created via NewScope or WithValues holds its own instance.
+	Scoped
+)
+
+// String returns the lowercase name of the lifetime.
+func (l Lifetime) String() string {
+	switch l {
+	case Singleton:
+		return "singleton"
+	case Transient:
+		return "transient"
+	case Scoped:
+		return "scoped"
+	}
+
+	return fmt.Sprintf("Lifetime(%d)", int(l))
+}
+
+// entry is a single registration within a container. An entry either holds a value given
+// directly to the container or a factory that constructs the value on demand. The type of
+// a factory's value is recorded when it is known ahead of time (see Provide).
+type entry struct {
+	key      interface{}
+	factory  Factory
+	lifetime Lifetime
+	typ      reflect.Type
+	instance instance
+
+	// owned is set for values registered via SetOwned
+	owned bool
+
+	// group is the name of the group the service was added to via AddToGroup, if any
+	group string
+
+	// constructor is the function registered via Provide, if any
+	constructor reflect.Value
+
+	// dependencies are the entries of services retrieved while constructing this entry's service
+	dependencies    []*entry
+	dependencyMutex sync.Mutex
+}
+
+// instance is a service value that is constructed at most once.
+type instance struct {
+	value interface{}
+	built bool
+	mutex sync.Mutex
+
+	// done is set once a constructed value has been stored so that it can be read without the mutex
+	done atomic.Bool
+}
+
+// resolve returns the value of the entry, invoking its factory if necessary. The owner is the
+// container layer that holds the entry and the origin is the container layer from which the
+// service was requested. Singleton factories are invoked with the owner, and transient and scoped
+// factories are invoked with the origin so that they may depend on values local to that layer.
+// The value is wrapped by any decorators visible from the origin (see Container.Decorate).
+func (e *entry) resolve(ctx context.Context, owner, origin *Container) (interface{}, error) {
+	if owner.isClosed() || origin.isClosed() {
+		return nil, ErrClosed
+	}
+
+	value, err := e.build(ctx, owner, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.decorate(ctx, value, owner, origin)
+}
+
+// build returns the undecorated value of the entry, invoking its factory if necessary.
+func (e *entry) build(ctx context.Context, owner, origin *Container) (interface{}, error) {
+	if e.factory == nil {
+		origin.recordDependency(e)
+		return e.instance.value, nil
+	}
+
+	// Singleton instances are owned by the owner and scoped instances are owned by the origin
+	i, holder := &e.instance, owner
+	switch e.lifetime {
+	case Transient:
+		i = nil
+	case Scoped:
+		i, holder = origin.scopedInstance(e), origin
+	}
+
+	if i != nil && i.done.Load() {
+		origin.recordDependency(e)
+		return i.value, nil
+	}
+
+	frame, err := origin.enterResolution(e)
+	if err != nil {
+		return nil, err
+	}
+	defer frame.leave()
+
+	if i == nil {
+		return e.construct(ctx, origin.withResolution(frame))
+	}
+
+	return i.get(ctx, e, holder.withResolution(frame))
+}
+
+// construct invokes the entry's factory.
+func (e *entry) construct(ctx context.Context, c *Container) (interface{}, error) {
+	value, err := e.factory(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct service %s: %w", prettyKey(e.key), err)
+	}
+
+	return value, nil
+}
+
+// get returns the instance's value, constructing it from the given entry if it has not yet
+// been built. Factory errors are not cached and the factory is retried on the next call. A newly
+// constructed value is owned by the given container (see Container.Close). If the container was
+// closed during construction, the value is closed and ErrClosed is returned.
+func (i *instance) get(ctx context.Context, e *entry, c *Container) (interface{}, error) {
+	if i.done.Load() {
+		return i.value, nil
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.built {
+		return i.value, nil
+	}
+
+	value, err := e.construct(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.own(ctx, e.key, value); err != nil {
+		return nil, err
+	}
+
+	i.value = value
+	i.built = true
+	i.done.Store(true)
+	return value, nil
+}
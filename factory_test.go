@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerSetFactory(t *testing.T) {
+	type T struct{ val int }
+
+	calls := 0
+	container := New()
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		calls++
+		return &T{10}, nil
+	}))
+	assert.Equal(t, 0, calls)
+
+	value1, err := container.Get("a")
+	require.Nil(t, err)
+	value2, err := container.Get("a")
+	require.Nil(t, err)
+
+	assert.Equal(t, &T{10}, value1)
+	assert.Same(t, value1, value2)
+	assert.Equal(t, 1, calls)
+}
+
+func TestContainerSetFactoryDependencies(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct{ dep *T1 }
+
+	container := New()
+	require.Nil(t, container.SetFactory("b", func(ctx context.Context, c *Container) (interface{}, error) {
+		dep, err := c.GetContext(ctx, "a")
+		if err != nil {
+			return nil, err
+		}
+
+		return &T2{dep.(*T1)}, nil
+	}))
+	require.Nil(t, container.Set("a", &T1{10}))
+
+	assertValue(t, container, "b", &T2{&T1{10}})
+}
+
+func TestContainerSetFactoryError(t *testing.T) {
+	calls := 0
+	container := New()
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		calls++
+		return nil, fmt.Errorf("oops")
+	}))
+
+	_, err := container.Get("a")
+	assert.EqualError(t, err, `failed to construct service "a": oops`)
+
+	// Errors are not cached
+	_, err = container.Get("a")
+	assert.EqualError(t, err, `failed to construct service "a": oops`)
+	assert.Equal(t, 2, calls)
+}
+
+func TestContainerSetFactoryDuplicateKey(t *testing.T) {
+	factory := func(ctx context.Context, c *Container) (interface{}, error) { return nil, nil }
+
+	container := New()
+	require.Nil(t, container.Set(testKey1{"dup"}, struct{}{}))
+	assert.EqualError(t, container.SetFactory("dup", factory), `duplicate service key "dup"`)
+	require.Nil(t, container.SetFactory(testKey2{"other"}, factory))
+	assert.EqualError(t, container.Set("other", struct{}{}), `duplicate service key "other"`)
+}
+
+func TestContainerSetFactoryInjectableServiceKey(t *testing.T) {
+	type T struct{ val int }
+
+	container := New()
+	require.Nil(t, container.SetFactory(testKey1{"foo"}, func(ctx context.Context, c *Container) (interface{}, error) {
+		return &T{10}, nil
+	}))
+
+	assertValue(t, container, "foo", &T{10})
+	assertValue(t, container, testKey1{"foo"}, &T{10})
+}
+
+func TestInjectFactory(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value"`
+	}
+
+	type ctxKey struct{}
+	container := New()
+	require.Nil(t, container.SetFactory("value", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &T1{ctx.Value(ctxKey{}).(int)}, nil
+	}))
+
+	obj := &T2{}
+	err := Inject(context.WithValue(context.Background(), ctxKey{}, 42), container, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42, obj.Value.val)
+}
+
+func TestInjectFactoryError(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value"`
+	}
+
+	container := New()
+	require.Nil(t, container.SetFactory("value", func(ctx context.Context, c *Container) (interface{}, error) {
+		return nil, fmt.Errorf("oops")
+	}))
+
+	err := Inject(context.Background(), container, &T2{})
+	assert.EqualError(t, err, `failed to construct service "value": oops`)
+}
+
+func TestInjectOptionalFactoryError(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value" optional:"true"`
+	}
+
+	container := New()
+	require.Nil(t, container.SetFactory("value", func(ctx context.Context, c *Container) (interface{}, error) {
+		return nil, fmt.Errorf("oops")
+	}))
+
+	err := Inject(context.Background(), container, &T2{})
+	assert.EqualError(t, err, `failed to construct service "value": oops`)
+}
+
+func TestContainerSetTransient(t *testing.T) {
+	type T struct{ val int }
+
+	calls := 0
+	container := New()
+	require.Nil(t, container.SetTransient("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		calls++
+		return &T{calls}, nil
+	}))
+
+	scope := container.NewScope()
+	assertValue(t, container, "a", &T{1})
+	assertValue(t, container, "a", &T{2})
+	assertValue(t, scope, "a", &T{3})
+	assert.Equal(t, 3, calls)
+}
+
+func TestContainerSetScoped(t *testing.T) {
+	type T struct{ val int }
+
+	calls := 0
+	container := New()
+	require.Nil(t, container.SetScoped("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		calls++
+		return &T{calls}, nil
+	}))
+
+	scope1 := container.NewScope()
+	scope2, err := container.WithValues(nil)
+	require.Nil(t, err)
+
+	assertValue(t, scope1, "a", &T{1})
+	assertValue(t, scope2, "a", &T{2})
+	assertValue(t, container, "a", &T{3})
+	assertValue(t, scope1, "a", &T{1})
+	assertValue(t, scope2, "a", &T{2})
+	assertValue(t, container, "a", &T{3})
+	assert.Equal(t, 3, calls)
+}
+
+func TestContainerSetScopedDependsOnScope(t *testing.T) {
+	type Pool struct{ name string }
+	type Tx struct {
+		pool      *Pool
+		requestID string
+	}
+
+	poolCalls := 0
+	container := New()
+	require.Nil(t, container.SetFactory("pool", func(ctx context.Context, c *Container) (interface{}, error) {
+		poolCalls++
+		return &Pool{"main"}, nil
+	}))
+	require.Nil(t, container.SetScoped("tx", func(ctx context.Context, c *Container) (interface{}, error) {
+		pool, err := c.GetContext(ctx, "pool")
+		if err != nil {
+			return nil, err
+		}
+		requestID, err := c.GetContext(ctx, "request-id")
+		if err != nil {
+			return nil, err
+		}
+
+		return &Tx{pool.(*Pool), requestID.(string)}, nil
+	}))
+
+	request1, err := container.WithValues(map[interface{}]interface{}{"request-id": "r1"})
+	require.Nil(t, err)
+	request2, err := container.WithValues(map[interface{}]interface{}{"request-id": "r2"})
+	require.Nil(t, err)
+
+	tx1, err := request1.Get("tx")
+	require.Nil(t, err)
+	tx2, err := request2.Get("tx")
+	require.Nil(t, err)
+
+	assert.Equal(t, "r1", tx1.(*Tx).requestID)
+	assert.Equal(t, "r2", tx2.(*Tx).requestID)
+	assert.Same(t, tx1.(*Tx).pool, tx2.(*Tx).pool)
+	assert.Equal(t, 1, poolCalls)
+}
+
+func TestLifetimeString(t *testing.T) {
+	assert.Equal(t, "singleton", Singleton.String())
+	assert.Equal(t, "transient", Transient.String())
+	assert.Equal(t, "scoped", Scoped.String())
+	assert.Equal(t, "Lifetime(7)", Lifetime(7).String())
+}
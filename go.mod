@@ -1,10 +1,13 @@
 module github.com/sourcegraph-testing/nacelle-service/v5
 
-go 1.16
+go 1.20
+
+require github.com/stretchr/testify v1.6.1
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/kr/pretty v0.2.1 // indirect
-	github.com/stretchr/testify v1.6.1
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// resolution is a frame of the chain of entries currently being constructed on behalf of a
+// single retrieval. The chain is carried by the container given to each factory (see Factory), so
+// that services retrieved through that container join the chain.
+type resolution struct {
+	e      *entry
+	parent *resolution
+
+	// left is set once the entry's factory has returned
+	left atomic.Bool
+}
+
+// currentResolution returns the innermost frame of the chain carried by this container, or nil if
+// the container was not given to a factory or the factory has since returned.
+func (c *Container) currentResolution() *resolution {
+	if c.resolution == nil || c.resolution.left.Load() {
+		return nil
+	}
+
+	return c.resolution
+}
+
+// withResolution returns a container sharing the state of this container that carries the given
+// resolution chain.
+func (c *Container) withResolution(frame *resolution) *Container {
+	return &Container{registry: c.registry, resolution: frame}
+}
+
+// recordDependency records the given entry as a dependency of the entry currently under
+// construction by the factory to which this container was given (if any).
+func (c *Container) recordDependency(e *entry) *resolution {
+	frame := c.currentResolution()
+	if frame != nil {
+		frame.e.addDependency(e)
+	}
+
+	return frame
+}
+
+// enterResolution records the given entry as a dependency of the entry currently under
+// construction (if any). If the given entry is already under construction in the same call
+// chain, a CycleError is returned. Otherwise, the extended chain is returned and should be
+// carried by the container passed to the entry's factory. The frame must be left once the
+// factory returns.
+func (c *Container) enterResolution(e *entry) (*resolution, error) {
+	frame := c.recordDependency(e)
+
+	for f := frame; f != nil; f = f.parent {
+		if f.e != e {
+			continue
+		}
+
+		path := []interface{}{e.key}
+		for f2 := frame; f2 != f; f2 = f2.parent {
+			path = append(path, f2.e.key)
+		}
+		path = append(path, e.key)
+
+		// Frames were collected from the innermost outward
+		for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
+		}
+
+		return nil, &CycleError{Path: path}
+	}
+
+	return &resolution{e: e, parent: frame}, nil
+}
+
+// leave marks the frame's factory as returned, so that the chain is not extended by a container
+// that the factory retained.
+func (r *resolution) leave() {
+	r.left.Store(true)
+}
+
+// addDependency records that the entry's service depends on the service of the given entry.
+func (e *entry) addDependency(dependency *entry) {
+	e.dependencyMutex.Lock()
+	defer e.dependencyMutex.Unlock()
+
+	for _, existing := range e.dependencies {
+		if existing == dependency {
+			return
+		}
+	}
+
+	e.dependencies = append(e.dependencies, dependency)
+}
+
+// Graph is a snapshot of the dependencies between the services visible from a container.
+type Graph struct {
+	keys         []interface{}
+	keysByTag    map[string]interface{}
+	dependencies map[interface{}][]interface{}
+	dependents   map[interface{}][]interface{}
+}
+
+// Graph returns the dependency graph of the services visible from this container. Dependencies
+// are discovered from the parameters of constructors registered via Provide, from the tagged
+// fields of struct values registered to the container, and from the services retrieved by
+// factories that have already been invoked through the container given to the factory.
+func (c *Container) Graph() *Graph {
+	g := &Graph{
+		keysByTag:    map[string]interface{}{},
+		dependencies: map[interface{}][]interface{}{},
+		dependents:   map[interface{}][]interface{}{},
+	}
+
+	candidates := c.visibleEntries()
+	for _, candidate := range candidates {
+		g.keys = append(g.keys, candidate.e.key)
+
+		if tag, ok := tagForKey(candidate.e.key); ok {
+			g.keysByTag[tag] = candidate.e.key
+		}
+	}
+
+	for _, candidate := range candidates {
+		for _, dependency := range c.dependencies(candidate.e) {
+			g.addEdge(candidate.e.key, dependency.key)
+		}
+	}
+
+	return g
+}
+
+// dependency is a service on which another service depends. The field is the name of the struct
+// field that requires the service, if the dependency was discovered from a struct tag.
+type dependency struct {
+	key   interface{}
+	field string
+}
+
+// dependencies returns the services on which the given entry depends. Keys are resolved relative
+// to this container.
+func (c *Container) dependencies(e *entry) []dependency {
+	var dependencies []dependency
+	if e.constructor.IsValid() {
+		ft := e.constructor.Type()
+		for i := 0; i < ft.NumIn(); i++ {
+			if t := ft.In(i); t != contextType && t != containerType {
+				dependencies = append(dependencies, dependency{key: c.typeDependency(t)})
+			}
+		}
+	}
+
+	if typ := e.typeOf(); typ != nil && e.factory == nil {
+		for _, field := range structDependencies(typ) {
+			if field.group != "" {
+				for _, member := range c.groupMembers(field.group) {
+					dependencies = append(dependencies, dependency{key: member.e.key, field: field.name})
+				}
+
+				continue
+			}
+
+			dependencies = append(dependencies, dependency{key: c.fieldDependency(field), field: field.name})
+		}
+	}
+
+	e.dependencyMutex.Lock()
+	defer e.dependencyMutex.Unlock()
+
+	for _, d := range e.dependencies {
+		dependencies = append(dependencies, dependency{key: d.key})
+	}
+
+	return dependencies
+}
+
+// typeDependency returns the key of the service that would be resolved for the given type, or
+// the type key itself if the type cannot be resolved unambiguously.
+func (c *Container) typeDependency(t reflect.Type) interface{} {
+	if e, _, ok, err := c.lookupType(t); ok && err == nil {
+		return e.key
+	}
+
+	return TypeKey(t)
+}
+
+// fieldDependency returns the key of the service that would be injected into the given field. If
+// neither the field's service nor its default service is registered, the requested key is returned.
+func (c *Container) fieldDependency(field fieldDependency) interface{} {
+	if field.byType {
+		e, _, ok, err := c.lookupType(field.typ)
+		if err != nil {
+			return TypeKey(field.typ)
+		}
+		if ok {
+			return e.key
+		}
+	} else if e, _, ok := c.lookup(field.key); ok {
+		return e.key
+	}
+
+	if field.fallback != "" {
+		if e, _, ok := c.lookup(field.fallback); ok {
+			return e.key
+		}
+	}
+
+	if field.byType {
+		return TypeKey(field.typ)
+	}
+
+	return field.key
+}
+
+// fieldDependency is a service required by a tagged struct field.
+type fieldDependency struct {
+	fieldTag
+	name string
+	typ  reflect.Type
+}
+
+// structDependencies returns the services required by the tagged fields of the given type,
+// including the fields of anonymous and inline struct fields. Non-struct types have no dependencies.
+func structDependencies(t reflect.Type) []fieldDependency {
+	return appendStructDependencies(nil, t, map[reflect.Type]struct{}{})
+}
+
+func appendStructDependencies(dependencies []fieldDependency, t reflect.Type, visited map[reflect.Type]struct{}) []fieldDependency {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return dependencies
+	}
+	if _, ok := visited[t]; ok {
+		return dependencies
+	}
+	visited[t] = struct{}{}
+
+	for _, f := range planFor(t).fields {
+		if f.err != nil {
+			continue
+		}
+
+		if f.anonymous || f.inline {
+			dependencies = appendStructDependencies(dependencies, f.field.Type, visited)
+		} else {
+			dependencies = append(dependencies, fieldDependency{fieldTag: f.fieldTag, name: f.field.Name, typ: f.field.Type})
+		}
+	}
+
+	return dependencies
+}
+
+// addEdge records that the service registered to the first key depends on the service registered
+// to the second key.
+func (g *Graph) addEdge(from, to interface{}) {
+	to = g.canonical(to)
+	for _, dependency := range g.dependencies[from] {
+		if dependency == to {
+			return
+		}
+	}
+
+	g.dependencies[from] = append(g.dependencies[from], to)
+	g.dependents[to] = append(g.dependents[to], from)
+}
+
+// canonical returns the key under which the service equivalent to the given key is registered.
+func (g *Graph) canonical(key interface{}) interface{} {
+	if tag, ok := tagForKey(key); ok {
+		if canonicalKey, ok := g.keysByTag[tag]; ok {
+			return canonicalKey
+		}
+	}
+
+	return key
+}
+
+// Keys returns the keys of all services in the graph in registration order, beginning with the
+// nearest container layer.
+func (g *Graph) Keys() []interface{} {
+	return append([]interface{}(nil), g.keys...)
+}
+
+// Dependencies returns the keys of the services on which the service registered to the given key
+// depends. A returned key may not be registered to the container.
+func (g *Graph) Dependencies(key interface{}) []interface{} {
+	return append([]interface{}(nil), g.dependencies[g.canonical(key)]...)
+}
+
+// Dependents returns the keys of the services which depend on the service registered to the given
+// key.
+func (g *Graph) Dependents(key interface{}) []interface{} {
+	return append([]interface{}(nil), g.dependents[g.canonical(key)]...)
+}
+
+// Cycle returns a CycleError describing a dependency cycle in the graph, or nil if the graph is
+// acyclic.
+func (g *Graph) Cycle() error {
+	_, err := g.sort(g.keys)
+	return err
+}
+
+// sort returns the given keys ordered so that every key follows the keys on which it depends,
+// directly or transitively. Keys that are not dependencies of one another retain their relative
+// order. Dependencies outside of the given keys are traversed but are not included in the result.
+// A CycleError is returned if a cycle is reachable from any of the given keys.
+func (g *Graph) sort(keys []interface{}) ([]interface{}, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	include := map[interface{}]struct{}{}
+	for _, key := range keys {
+		include[g.canonical(key)] = struct{}{}
+	}
+
+	state := map[interface{}]int{}
+	var path, sorted []interface{}
+
+	var visit func(key interface{}) error
+	visit = func(key interface{}) error {
+		switch state[key] {
+		case visited:
+			return nil
+		case visiting:
+			for i, k := range path {
+				if k == key {
+					return &CycleError{Path: append(append([]interface{}(nil), path[i:]...), key)}
+				}
+			}
+		}
+
+		state[key] = visiting
+		path = append(path, key)
+		for _, dependency := range g.dependencies[key] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+
+		if _, ok := include[key]; ok {
+			sorted = append(sorted, key)
+		}
+
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := visit(g.canonical(key)); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
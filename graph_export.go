@@ -0,0 +1,227 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// graphDocument is the serialized form of the services and dependencies of a container and each
+// of its parent layers.
+type graphDocument struct {
+	Layers   []graphLayer   `json:"layers"`
+	Services []graphService `json:"services"`
+	Edges    []graphEdge    `json:"edges"`
+}
+
+// graphLayer describes a single container layer. Layer zero is the root container and each
+// subsequent layer is an overlay created from the previous layer (see WithValues).
+type graphLayer struct {
+	Index int  `json:"index"`
+	Root  bool `json:"root"`
+}
+
+// graphService describes a single registration. Services that are referenced by another service
+// but are not registered to any layer are marked as missing and have a layer index of -1.
+type graphService struct {
+	ID       string `json:"id"`
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Tag      string `json:"tag,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Layer    int    `json:"layer"`
+	Shadowed bool   `json:"shadowed,omitempty"`
+	Missing  bool   `json:"missing,omitempty"`
+}
+
+// graphEdge describes a dependency of one service on another. The field is set when the dependency
+// was discovered from a tagged struct field.
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Field string `json:"field,omitempty"`
+}
+
+// WriteGraphJSON writes a JSON document describing the services registered to this container and
+// each of its parent layers, along with the dependencies between them (see Graph). The document
+// is stable for a given set of registrations.
+func (c *Container) WriteGraphJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.exportGraph())
+}
+
+// WriteGraphDOT writes a Graphviz DOT description of the services registered to this container and
+// each of its parent layers, along with the dependencies between them (see Graph). Each layer is
+// rendered as a cluster, and overlays are drawn with a dashed border to distinguish them from the
+// root container. Services shadowed by a nearer layer are drawn with a dotted border.
+func (c *Container) WriteGraphDOT(w io.Writer) error {
+	doc := c.exportGraph()
+
+	p := &dotPrinter{w: w}
+	p.printf("digraph services {\n")
+	p.printf("\trankdir=LR;\n")
+	p.printf("\tnode [shape=box];\n")
+
+	for _, layer := range doc.Layers {
+		label := "root"
+		if !layer.Root {
+			label = fmt.Sprintf("overlay %d", layer.Index)
+		}
+
+		p.printf("\tsubgraph cluster_%d {\n", layer.Index)
+		p.printf("\t\tlabel=%s;\n", strconv.Quote(label))
+		if !layer.Root {
+			p.printf("\t\tstyle=dashed;\n")
+		}
+
+		for _, service := range doc.Services {
+			if service.Layer == layer.Index {
+				p.printService("\t\t", service)
+			}
+		}
+
+		p.printf("\t}\n")
+	}
+
+	for _, service := range doc.Services {
+		if service.Missing {
+			p.printService("\t", service)
+		}
+	}
+
+	for _, edge := range doc.Edges {
+		if edge.Field != "" {
+			p.printf("\t%s -> %s [label=%s];\n", edge.From, edge.To, strconv.Quote(edge.Field))
+		} else {
+			p.printf("\t%s -> %s;\n", edge.From, edge.To)
+		}
+	}
+
+	p.printf("}\n")
+	return p.err
+}
+
+// exportGraph builds a graph document from this container and its parent layers.
+func (c *Container) exportGraph() graphDocument {
+	layers := c.layers()
+
+	doc := graphDocument{
+		Layers:   []graphLayer{},
+		Services: []graphService{},
+		Edges:    []graphEdge{},
+	}
+
+	ids := map[*entry]string{}
+	entriesByLayer := make([][]*entry, len(layers))
+	for i, layer := range layers {
+		doc.Layers = append(doc.Layers, graphLayer{Index: i, Root: i == 0})
+
+		layer.mutex.RLock()
+		entriesByLayer[i] = append([]*entry(nil), layer.entries...)
+		layer.mutex.RUnlock()
+
+		for _, e := range entriesByLayer[i] {
+			ids[e] = fmt.Sprintf("n%d", len(doc.Services))
+			doc.Services = append(doc.Services, newGraphService(ids[e], e.key, e.concreteType(), i, shadowed(e, layers[i+1:])))
+		}
+	}
+
+	missing := map[interface{}]string{}
+	for i, layer := range layers {
+		for _, e := range entriesByLayer[i] {
+			for _, dependency := range layer.dependencies(e) {
+				var to string
+				if target, _, ok := layer.lookup(dependency.key); ok {
+					to = ids[target]
+				} else if id, ok := missing[dependency.key]; ok {
+					to = id
+				} else {
+					to = fmt.Sprintf("n%d", len(doc.Services))
+					missing[dependency.key] = to
+
+					service := newGraphService(to, dependency.key, nil, -1, false)
+					service.Missing = true
+					doc.Services = append(doc.Services, service)
+				}
+
+				doc.Edges = append(doc.Edges, graphEdge{From: ids[e], To: to, Field: dependency.field})
+			}
+		}
+	}
+
+	return doc
+}
+
+func newGraphService(id string, key interface{}, typ reflect.Type, layer int, shadowed bool) graphService {
+	service := graphService{
+		ID:       id,
+		Key:      describeKey(key),
+		Name:     prettyKey(key),
+		Layer:    layer,
+		Shadowed: shadowed,
+	}
+
+	if tag, ok := tagForKey(key); ok {
+		service.Tag = tag
+	}
+	if typ != nil {
+		service.Type = typ.String()
+	}
+
+	return service
+}
+
+// describeKey returns a Go-syntax representation of the given service key.
+func describeKey(key interface{}) string {
+	if k, ok := key.(typeKey); ok {
+		return fmt.Sprintf("TypeKey(%s)", k.t)
+	}
+
+	return fmt.Sprintf("%#v", key)
+}
+
+// concreteType returns the type of the entry's service. Unlike typeOf, this includes the type of
+// values already constructed by a factory. Nil is returned if the type is not yet known.
+func (e *entry) concreteType() reflect.Type {
+	if e.factory != nil && e.instance.mutex.TryLock() {
+		defer e.instance.mutex.Unlock()
+
+		if e.instance.built && e.instance.value != nil {
+			return reflect.TypeOf(e.instance.value)
+		}
+	}
+
+	return e.typeOf()
+}
+
+// dotPrinter writes formatted DOT output and retains the first write error.
+type dotPrinter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *dotPrinter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *dotPrinter) printService(indent string, service graphService) {
+	label := service.Name
+	if service.Type != "" {
+		label += "\n" + service.Type
+	}
+
+	attributes := "label=" + strconv.Quote(label)
+	if service.Shadowed {
+		attributes += ", style=dotted"
+	}
+	if service.Missing {
+		attributes += ", style=dashed, color=red"
+	}
+
+	p.printf("%s%s [%s];\n", indent, service.ID, attributes)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testExportContainer(t *testing.T) *Container {
+	type T struct {
+		Value *TI `service:"value"`
+		Other *TI `service:"missing"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set(testKey1{"a"}, &T{}))
+	require.Nil(t, container.Provide(func(logger testLogger) *testDB { return nil }))
+
+	overlay, err := container.WithValues(map[interface{}]interface{}{"value": &TI{25}})
+	require.Nil(t, err)
+	return overlay
+}
+
+func TestWriteGraphDOT(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, testExportContainer(t).WriteGraphDOT(&buf))
+
+	expected := `digraph services {
+	rankdir=LR;
+	node [shape=box];
+	subgraph cluster_0 {
+		label="root";
+		n0 [label="\"value\"\n*service.TI", style=dotted];
+		n1 [label="testKey1 (\"a\")\n*service.T"];
+		n2 [label="*service.testDB\n*service.testDB"];
+	}
+	subgraph cluster_1 {
+		label="overlay 1";
+		style=dashed;
+		n3 [label="\"value\"\n*service.TI"];
+	}
+	n4 [label="\"missing\"", style=dashed, color=red];
+	n5 [label="service.testLogger", style=dashed, color=red];
+	n1 -> n0 [label="Value"];
+	n1 -> n4 [label="Other"];
+	n2 -> n5;
+}
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteGraphJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, testExportContainer(t).WriteGraphJSON(&buf))
+
+	var doc graphDocument
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &doc))
+
+	assert.Equal(t, []graphLayer{{Index: 0, Root: true}, {Index: 1}}, doc.Layers)
+	assert.Equal(t, []graphService{
+		{ID: "n0", Key: `"value"`, Name: `"value"`, Tag: "value", Type: "*service.TI", Layer: 0, Shadowed: true},
+		{ID: "n1", Key: `service.testKey1{name:"a"}`, Name: `testKey1 ("a")`, Tag: "a", Type: "*service.T", Layer: 0},
+		{ID: "n2", Key: "TypeKey(*service.testDB)", Name: "*service.testDB", Type: "*service.testDB", Layer: 0},
+		{ID: "n3", Key: `"value"`, Name: `"value"`, Tag: "value", Type: "*service.TI", Layer: 1},
+		{ID: "n4", Key: `"missing"`, Name: `"missing"`, Tag: "missing", Layer: -1, Missing: true},
+		{ID: "n5", Key: "TypeKey(service.testLogger)", Name: "service.testLogger", Layer: -1, Missing: true},
+	}, doc.Services)
+	assert.Equal(t, []graphEdge{
+		{From: "n1", To: "n0", Field: "Value"},
+		{From: "n1", To: "n4", Field: "Other"},
+		{From: "n2", To: "n5"},
+	}, doc.Edges)
+
+	// Output is stable between calls
+	var buf2 bytes.Buffer
+	require.Nil(t, testExportContainer(t).WriteGraphJSON(&buf2))
+	assert.Equal(t, buf.String(), buf2.String())
+}
+
+func TestWriteGraphJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, New().WriteGraphJSON(&buf))
+	assert.JSONEq(t, `{"layers": [{"index": 0, "root": true}], "services": [], "edges": []}`, buf.String())
+}
+
+func TestWriteGraphJSONStableOverlay(t *testing.T) {
+	newOverlay := func() *Container {
+		container := New()
+		require.Nil(t, container.Set("root", &TI{1}))
+
+		overlay, err := container.WithValues(map[interface{}]interface{}{
+			"d":             &TI{4},
+			"b":             &TI{2},
+			testKey3{"c"}:   &TI{3},
+			testKey1{"a"}:   &TI{1},
+			"e":             &TI{5},
+			testKey3{"aaa"}: &TI{6},
+		})
+		require.Nil(t, err)
+		return overlay
+	}
+
+	var expected bytes.Buffer
+	require.Nil(t, newOverlay().WriteGraphJSON(&expected))
+
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+		require.Nil(t, newOverlay().WriteGraphJSON(&buf))
+		require.Equal(t, expected.String(), buf.String())
+	}
+
+	assert.Equal(t, []interface{}{"root", "b", "d", "e", testKey1{"a"}, testKey3{"aaa"}, testKey3{"c"}}, newOverlay().Keys())
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerFactoryCycle(t *testing.T) {
+	container := New()
+	for key, dependency := range map[string]string{"a": "b", "b": "c", "c": "a"} {
+		dependency := dependency
+		require.Nil(t, container.SetFactory(key, func(ctx context.Context, c *Container) (interface{}, error) {
+			return c.GetContext(ctx, dependency)
+		}))
+	}
+
+	_, err := container.Get("a")
+	require.NotNil(t, err)
+
+	var cycleErr *CycleError
+	require.True(t, errors.As(err, &cycleErr))
+	assert.Equal(t, []interface{}{"a", "b", "c", "a"}, cycleErr.Path)
+	assert.EqualError(t, cycleErr, `dependency cycle detected: "a" -> "b" -> "c" -> "a"`)
+}
+
+func TestContainerFactorySelfCycle(t *testing.T) {
+	container := New()
+	require.Nil(t, container.SetScoped("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.GetContext(ctx, "a")
+	}))
+
+	_, err := container.NewScope().Get("a")
+	assert.EqualError(t, err, `failed to construct service "a": dependency cycle detected: "a" -> "a"`)
+}
+
+func TestContainerFactoryCycleWithoutContext(t *testing.T) {
+	container := New()
+	for key, dependency := range map[string]string{"a": "b", "b": "c", "c": "a"} {
+		dependency := dependency
+		require.Nil(t, container.SetFactory(key, func(ctx context.Context, c *Container) (interface{}, error) {
+			return c.Get(dependency)
+		}))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := container.Get("a")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		var cycleErr *CycleError
+		require.True(t, errors.As(err, &cycleErr))
+		assert.Equal(t, []interface{}{"a", "b", "c", "a"}, cycleErr.Path)
+	case <-time.After(time.Second):
+		t.Fatal("deadlock resolving dependency cycle")
+	}
+
+	require.Nil(t, container.SetTransient("d", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.Get("d")
+	}))
+
+	_, err := container.Get("d")
+	assert.EqualError(t, err, `failed to construct service "d": dependency cycle detected: "d" -> "d"`)
+}
+
+func TestContainerFactoryDependenciesWithoutContext(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.SetFactory("inner", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.Get("value")
+	}))
+	require.Nil(t, container.SetFactory("outer", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.Get("inner")
+	}))
+
+	_, err := container.Get("outer")
+	require.Nil(t, err)
+
+	graph := container.Graph()
+	assert.Equal(t, []interface{}{"inner"}, graph.Dependencies("outer"))
+	assert.Equal(t, []interface{}{"value"}, graph.Dependencies("inner"))
+}
+
+func TestContainerFactoryConcurrentWithoutContext(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	container := New()
+	require.Nil(t, container.SetFactory("slow", func(ctx context.Context, c *Container) (interface{}, error) {
+		close(started)
+		<-release
+		return &TI{42}, nil
+	}))
+
+	go func() {
+		<-started
+		close(release)
+	}()
+
+	// Concurrent retrievals on other goroutines wait rather than report a cycle
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := container.Get("slow")
+			assert.Nil(t, err)
+			assert.Equal(t, &TI{42}, value)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestContainerFactoryOtherContainer(t *testing.T) {
+	other := New()
+	require.Nil(t, other.Set("b", &TI{42}))
+
+	container := New()
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		return other.Get("b")
+	}))
+	require.Nil(t, container.SetFactory("b", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.Get("a")
+	}))
+
+	value, err := container.Get("b")
+	require.Nil(t, err)
+	assert.Equal(t, &TI{42}, value)
+
+	// Services retrieved from other containers are not dependencies
+	graph := container.Graph()
+	assert.Empty(t, graph.Dependencies("a"))
+	assert.Equal(t, []interface{}{"a"}, graph.Dependencies("b"))
+	assert.Nil(t, graph.Cycle())
+	assert.Nil(t, container.Start(context.Background()))
+}
+
+func TestContainerFactoryRetainedContainer(t *testing.T) {
+	var retained *Container
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		retained = c
+		return &TI{25}, nil
+	}))
+
+	_, err := container.Get("a")
+	require.Nil(t, err)
+
+	// Retrievals made after the factory returns are not dependencies
+	value, err := retained.Get("a")
+	require.Nil(t, err)
+	assert.Equal(t, &TI{25}, value)
+	_, err = retained.Get("value")
+	require.Nil(t, err)
+	assert.Empty(t, container.Graph().Dependencies("a"))
+}
+
+func TestContainerInjectCycle(t *testing.T) {
+	type T struct {
+		Value interface{} `service:"a"`
+	}
+
+	container := New()
+	require.Nil(t, container.SetFactory("a", func(ctx context.Context, c *Container) (interface{}, error) {
+		obj := &T{}
+		if err := Inject(ctx, c, obj); err != nil {
+			return nil, err
+		}
+
+		return obj, nil
+	}))
+
+	_, err := container.Get("a")
+	assert.EqualError(t, err, `failed to construct service "a": dependency cycle detected: "a" -> "a"`)
+}
+
+func TestGraph(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set("process", &testPostInjectProcess{}))
+	require.Nil(t, container.Set("services", container))
+	require.Nil(t, container.Set(testKey1{"logger"}, &testLoggerImpl{}))
+	require.Nil(t, container.Provide(func() *testDB { return &testDB{} }))
+	require.Nil(t, container.Provide(newTestStore))
+	require.Nil(t, container.SetFactory("handler", func(ctx context.Context, c *Container) (interface{}, error) {
+		if _, err := c.GetContext(ctx, "logger"); err != nil {
+			return nil, err
+		}
+
+		return c.GetContext(ctx, "value")
+	}))
+
+	storeKey := TypeKey(reflect.TypeOf(&testStore{}))
+	dbKey := TypeKey(reflect.TypeOf(&testDB{}))
+
+	graph := container.Graph()
+	assert.Equal(t, []interface{}{"value", "process", "services", testKey1{"logger"}, dbKey, storeKey, "handler"}, graph.Keys())
+	assert.Equal(t, []interface{}{"value"}, graph.Dependencies("process"))
+	assert.Equal(t, []interface{}{dbKey, testKey1{"logger"}}, graph.Dependencies(storeKey))
+	assert.Empty(t, graph.Dependencies("handler"))
+	assert.Equal(t, []interface{}{"process"}, graph.Dependents("value"))
+	assert.Equal(t, []interface{}{storeKey}, graph.Dependents("logger"))
+	assert.Nil(t, graph.Cycle())
+
+	// Factory dependencies are discovered once invoked
+	_, err := container.Get("handler")
+	require.Nil(t, err)
+
+	graph = container.Graph()
+	assert.Equal(t, []interface{}{testKey1{"logger"}, "value"}, graph.Dependencies("handler"))
+	assert.Equal(t, []interface{}{"process", "handler"}, graph.Dependents("value"))
+}
+
+func TestGraphOverlay(t *testing.T) {
+	type T struct {
+		Value *TI `service:"value"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set("a", &T{}))
+
+	overlay, err := container.WithValues(map[interface{}]interface{}{"value": &TI{25}, "b": &T{}})
+	require.Nil(t, err)
+
+	graph := overlay.Graph()
+	assert.ElementsMatch(t, []interface{}{"value", "b", "a"}, graph.Keys())
+	assert.ElementsMatch(t, []interface{}{"a", "b"}, graph.Dependents("value"))
+}
+
+func TestGraphDefault(t *testing.T) {
+	type T struct {
+		Value *TI `service:"value,default=fallback"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("fallback", &TI{42}))
+	require.Nil(t, container.Set("a", &T{}))
+	assert.Equal(t, []interface{}{"fallback"}, container.Graph().Dependencies("a"))
+
+	require.Nil(t, container.Set("value", &TI{25}))
+	assert.Equal(t, []interface{}{"value"}, container.Graph().Dependencies("a"))
+}
+
+func TestGraphInline(t *testing.T) {
+	type Deps struct {
+		Value *TI `service:"value"`
+	}
+	type T struct {
+		Deps *Deps `service:",inline"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set("a", &T{}))
+	assert.Equal(t, []interface{}{"value"}, container.Graph().Dependencies("a"))
+}
+
+func TestGraphCycle(t *testing.T) {
+	type A struct {
+		B interface{} `service:"b"`
+	}
+	type B struct {
+		C interface{} `service:"c"`
+	}
+	type C struct {
+		A interface{} `service:"a"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("a", &A{}))
+	require.Nil(t, container.Set("b", &B{}))
+	require.Nil(t, container.Set("c", &C{}))
+
+	assert.EqualError(t, container.Graph().Cycle(), `dependency cycle detected: "a" -> "b" -> "c" -> "a"`)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AddToGroup registers a service with the given key, as with Set, and adds it to the given group.
+// A struct field tagged with `service:"group:name"` is populated by every member of the group that
+// is visible from the container being injected. Slice fields hold the members in registration order,
+// starting with the members of the root container. Map fields with string keys hold the members by
+// the tag of their service key (see InjectableServiceKey).
+func (c *Container) AddToGroup(group string, key, service interface{}) error {
+	return c.set(&entry{key: key, instance: instance{value: service, built: true}, group: group})
+}
+
+// AddToGroupLocal registers a service with the given key in this container layer only, as with
+// SetLocal, and adds it to the given group (see AddToGroup).
+func (c *Container) AddToGroupLocal(group string, key, service interface{}) error {
+	return c.setLocal(&entry{key: key, instance: instance{value: service, built: true}, group: group})
+}
+
+// groupMembers returns the members of the given group visible from this container, ordered from
+// the root layer to the nearest layer and by registration order within each layer. A member that
+// is shadowed by a service with an equivalent key in a nearer layer is omitted, and the shadowing
+// service is a member only if it was itself added to the group.
+func (c *Container) groupMembers(group string) []candidate {
+	var layers [][]candidate
+	for _, candidate := range c.visibleEntries() {
+		if n := len(layers); n == 0 || layers[n-1][0].owner != candidate.owner {
+			layers = append(layers, nil)
+		}
+
+		layers[len(layers)-1] = append(layers[len(layers)-1], candidate)
+	}
+
+	var members []candidate
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, candidate := range layers[i] {
+			if candidate.e.group == group {
+				members = append(members, candidate)
+			}
+		}
+	}
+
+	return members
+}
+
+// loadGroupField sets the value of the given slice or map struct field to the members of the group
+// named by the field's service tag. During a dry run, the members are only checked against the
+// field's element type. This function returns true if the field was updated.
+func (i *injector) loadGroupField(f fieldPlan, fieldValue reflect.Value) (bool, error) {
+	if !fieldValue.CanSet() {
+		return false, fmt.Errorf("field '%s' can not be set - it may be unexported", f.field.Name)
+	}
+
+	members := i.c.groupMembers(f.group)
+	strict := f.strict || i.c.options.strictTypes
+	fieldType := fieldValue.Type()
+	elemType := fieldType.Elem()
+
+	var groupValue reflect.Value
+	if fieldType.Kind() == reflect.Map {
+		groupValue = reflect.MakeMapWithSize(fieldType, len(members))
+	} else {
+		groupValue = reflect.MakeSlice(fieldType, 0, len(members))
+	}
+
+	for _, member := range members {
+		tag, hasTag := tagForKey(member.e.key)
+		if fieldType.Kind() == reflect.Map && !hasTag {
+			return false, fmt.Errorf("field '%s' cannot hold service %s: service key has no tag", f.field.Name, prettyKey(member.e.key))
+		}
+
+		if i.dryRun {
+			if err := checkServiceField(member.e, f.field, elemType, strict); err != nil {
+				return false, err
+			}
+
+			continue
+		}
+
+		value, err := member.e.resolve(i.ctx, member.owner, i.c)
+		if err != nil {
+			return false, err
+		}
+
+		memberValue := reflect.ValueOf(value)
+		if !memberValue.IsValid() || !compatible(memberValue.Type(), elemType, strict) {
+			return false, &TypeMismatchError{Key: member.e.key, Field: f.field.Name, Want: elemType, Got: reflect.TypeOf(value), Strict: strict}
+		}
+		if !strict {
+			memberValue = memberValue.Convert(elemType)
+		}
+
+		if fieldType.Kind() == reflect.Map {
+			groupValue.SetMapIndex(reflect.ValueOf(tag).Convert(fieldType.Key()), memberValue)
+		} else {
+			groupValue = reflect.Append(groupValue, memberValue)
+		}
+	}
+
+	if i.dryRun {
+		return false, nil
+	}
+
+	fieldValue.Set(groupValue)
+	return true, nil
+}
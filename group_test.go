@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testChecker interface {
+	Check() string
+}
+
+type testCheckerImpl struct{ name string }
+
+func (c *testCheckerImpl) Check() string { return c.name }
+
+func TestInjectGroup(t *testing.T) {
+	type T struct {
+		Checkers []testChecker          `service:"group:health"`
+		ByKey    map[string]testChecker `service:"group:health"`
+		Empty    []testChecker          `service:"group:empty"`
+	}
+
+	container := New()
+	require.Nil(t, container.AddToGroup("health", "db", &testCheckerImpl{"db"}))
+	require.Nil(t, container.Set("unrelated", &testCheckerImpl{"unrelated"}))
+	require.Nil(t, container.AddToGroup("health", testKey1{"cache"}, &testCheckerImpl{"cache"}))
+	require.Nil(t, container.AddToGroup("metrics", "queue", &testCheckerImpl{"queue"}))
+
+	obj := &T{}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	require.Len(t, obj.Checkers, 2)
+	assert.Equal(t, "db", obj.Checkers[0].Check())
+	assert.Equal(t, "cache", obj.Checkers[1].Check())
+	require.Len(t, obj.ByKey, 2)
+	assert.Equal(t, "db", obj.ByKey["db"].Check())
+	assert.Equal(t, "cache", obj.ByKey["cache"].Check())
+	assert.NotNil(t, obj.Empty)
+	assert.Empty(t, obj.Empty)
+
+	// Group members are ordinary services
+	value, err := container.Get("db")
+	require.Nil(t, err)
+	assert.Equal(t, "db", value.(testChecker).Check())
+
+	err = container.AddToGroup("health", "db", &testCheckerImpl{"duplicate"})
+	assert.EqualError(t, err, `duplicate service key "db"`)
+}
+
+func TestInjectGroupOverlay(t *testing.T) {
+	type T struct {
+		Checkers []testChecker `service:"group:health"`
+	}
+
+	container := New()
+	require.Nil(t, container.AddToGroup("health", "db", &testCheckerImpl{"db"}))
+	require.Nil(t, container.AddToGroup("health", "cache", &testCheckerImpl{"cache"}))
+
+	overlay := container.NewScope()
+	require.Nil(t, overlay.AddToGroupLocal("health", "queue", &testCheckerImpl{"queue"}))
+	require.Nil(t, overlay.AddToGroupLocal("health", "db", &testCheckerImpl{"local db"}))
+	require.Nil(t, overlay.SetLocal("cache", &testCheckerImpl{"not a member"}))
+
+	scope := overlay.NewScope()
+	require.Nil(t, scope.AddToGroup("health", "search", &testCheckerImpl{"search"}))
+
+	obj := &T{}
+	require.Nil(t, Inject(context.Background(), scope, obj))
+
+	var names []string
+	for _, checker := range obj.Checkers {
+		names = append(names, checker.Check())
+	}
+	assert.Equal(t, []string{"search", "queue", "local db"}, names)
+
+	obj = &T{}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	require.Len(t, obj.Checkers, 3)
+	assert.Equal(t, "cache", obj.Checkers[1].Check())
+}
+
+func TestInjectGroupErrors(t *testing.T) {
+	type T struct {
+		Mismatch []*TI                  `service:"group:health"`
+		Untagged map[string]testChecker `service:"group:untagged"`
+		Scalar   testChecker            `service:"group:health"`
+		ByType   []testChecker          `service:"group:health,type"`
+		Unnamed  []testChecker          `service:"group:"`
+	}
+
+	container := New()
+	require.Nil(t, container.AddToGroup("health", "db", &testCheckerImpl{"db"}))
+	require.Nil(t, container.AddToGroup("untagged", testKey3{"db"}, &testCheckerImpl{"db"}))
+
+	err := InjectAll(context.Background(), container, &T{})
+	assert.EqualError(t, err, `5 fields could not be injected:
+	T.Mismatch: field 'Mismatch' cannot be assigned a value of type *service.testCheckerImpl
+	T.Untagged: field 'Untagged' cannot hold service testKey3: service key has no tag
+	T.Scalar: field 'Scalar' has an invalid service tag: a group requires a slice or map with string keys
+	T.ByType: field 'ByType' has an invalid service tag: option type cannot be combined with a group
+	T.Unnamed: field 'Unnamed' has an invalid service tag: missing group name`)
+
+	err = container.Validate(&T{})
+	assert.Contains(t, err.Error(), "T.Mismatch: field 'Mismatch' cannot be assigned a value of type *service.testCheckerImpl")
+}
+
+func TestGraphGroup(t *testing.T) {
+	type T struct {
+		Checkers []testChecker `service:"group:health"`
+	}
+
+	container := New()
+	require.Nil(t, container.AddToGroup("health", "db", &testCheckerImpl{"db"}))
+	require.Nil(t, container.AddToGroup("health", "cache", &testCheckerImpl{"cache"}))
+	require.Nil(t, container.Set("server", &T{}))
+
+	assert.Equal(t, []interface{}{"db", "cache"}, container.Graph().Dependencies("server"))
+}
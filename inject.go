@@ -4,41 +4,104 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"strconv"
 )
 
 // Inject will attempt to populate the given type with values from the service container based on
-// the value's struct tags. An error may occur if a service has not been registered, a service has
-// a different type than expected, or struct tags are malformed.
+// the value's struct tags. A field tagged with `service:"key"` is populated by the service registered
+// to that key, and a field tagged with `service:",type"` is populated by the single service assignable
+// to the field's type. The fields of a struct field tagged with `service:",inline"` are injected
+// recursively. A nested pointer field whose type is already being injected is followed only if it is
+// non-nil, and a nil field ends the chain of such values. A nil field that does not end a chain (e.g.
+// a nil field of the root object's own type) would be allocated indefinitely and is an error. An error
+// may also occur if a service has not been registered, a service has a different type than expected,
+// a service type is ambiguous, or struct tags are malformed.
 func Inject(ctx context.Context, c *Container, obj interface{}) error {
-	_, err := inject(ctx, c, obj, nil, nil)
+	i := &injector{ctx: ctx, c: c}
+	_, err := i.inject(obj, reflect.ValueOf(obj), rootPath(obj))
 	return err
 }
 
-// inject populates fields of the given struct. The root parameter should always point to the top
-// of the struct object. Passing nil will set the root to be the reflected value of the given object.
-// The given integer path should be the field index path to the object from the root of the struct.
-// This function returns true if the struct value was updated. If the object conforms to the PostInject
-// interface, its hook is called after successful injection.
-func inject(ctx context.Context, c *Container, obj interface{}, root *reflect.Value, path []int) (bool, error) {
-	oi := reflect.Indirect(reflect.ValueOf(obj))
-	if oi.Kind() != reflect.Struct {
+// InjectAll behaves like Inject, but continues past fields that cannot be injected. If any field
+// fails, an *InjectionError listing every failed field is returned. The PostInject hook of a struct
+// is not called if any of its fields (including the fields of nested struct fields) failed.
+func InjectAll(ctx context.Context, c *Container, obj interface{}) error {
+	i := &injector{ctx: ctx, c: c, collect: true}
+	if _, err := i.inject(obj, reflect.ValueOf(obj), rootPath(obj)); err != nil {
+		return err
+	}
+
+	if len(i.errs) > 0 {
+		return &InjectionError{Errors: i.errs}
+	}
+
+	return nil
+}
+
+// injector holds the state of a single call to Inject, InjectAll, or Validate. If collect is set,
+// field errors are accumulated rather than returned. If dryRun is set, fields are checked against
+// the container but are not assigned, factories are not invoked, and PostInject hooks are skipped.
+type injector struct {
+	ctx     context.Context
+	c       *Container
+	collect bool
+	dryRun  bool
+	errs    []*FieldError
+
+	// structs is the stack of struct values being injected, used to reject recursive nested fields
+	structs []nestedStruct
+
+	// linked is set while descending into a non-nil nested field whose type is already being injected
+	linked bool
+}
+
+// nestedStruct is a struct value being injected. The address is zero if the value is not addressable.
+// A struct is linked if it was reached through a non-nil field of a type that was already being
+// injected, which makes it part of a chain of values supplied by the caller.
+type nestedStruct struct {
+	typ    reflect.Type
+	addr   uintptr
+	linked bool
+}
+
+// rootPath returns the name of the type of the given object, used as the first component of the
+// field paths reported by InjectAll.
+func rootPath(obj interface{}) string {
+	t := reflect.TypeOf(obj)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return ""
+	}
+
+	return t.Name()
+}
+
+// inject populates fields of the given struct value. The given object is the value checked for the
+// PostInject interface and the given path is the dotted field path to the struct value from the root
+// object. This function returns true if the struct value was updated. If the object conforms to the
+// PostInject interface, its hook is called after successful injection.
+func (i *injector) inject(obj interface{}, v reflect.Value, path string) (bool, error) {
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
 		return false, nil
 	}
 
-	ot := oi.Type()
+	numErrs := len(i.errs)
 
-	if root == nil {
-		root = &oi
+	s := nestedStruct{typ: v.Type(), linked: i.linked}
+	if v.CanAddr() {
+		s.addr = v.Addr().Pointer()
 	}
+	i.linked = false
 
-	updated := false
-	for i := 0; i < ot.NumField(); i++ {
-		fieldPath := make([]int, len(path), len(path)+1)
-		copy(path, path)
-		fieldPath = append(fieldPath, i)
+	i.structs = append(i.structs, s)
+	defer func() { i.structs = i.structs[:len(i.structs)-1] }()
 
-		fieldUpdated, err := injectField(ctx, c, ot.Field(i), root, fieldPath)
+	updated := false
+	for _, f := range planFor(v.Type()).fields {
+		fieldUpdated, err := i.injectField(f, v.Field(f.index), path)
 		if err != nil {
 			return false, err
 		}
@@ -46,8 +109,13 @@ func inject(ctx context.Context, c *Container, obj interface{}, root *reflect.Va
 		updated = updated || fieldUpdated
 	}
 
+	if len(i.errs) > numErrs || i.dryRun {
+		// Do not run hooks on partially injected values
+		return updated, nil
+	}
+
 	if pi, ok := obj.(PostInject); ok {
-		if err := pi.PostInject(ctx); err != nil {
+		if err := pi.PostInject(i.ctx); err != nil {
 			return false, err
 		}
 	}
@@ -55,45 +123,123 @@ func inject(ctx context.Context, c *Container, obj interface{}, root *reflect.Va
 	return updated, nil
 }
 
-const (
-	serviceTag  = "service"
-	optionalTag = "optional"
-)
+// joinPath appends the given field name to the given field path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
 
-// injectField recursively sets the value of the given struct field. This uses the service struct tag
-// as the service key to match in the given container. If the field is a nested anonymous struct, its
-// fields are injected recursively. This function returns true if the field was updated.
-func injectField(ctx context.Context, c *Container, fieldType reflect.StructField, root *reflect.Value, indexPath []int) (bool, error) {
-	if fieldType.Anonymous {
-		return injectAnonymousField(ctx, c, fieldType, root, indexPath)
+// injectField recursively sets the value of the given struct field as described by its plan. This
+// uses the service struct tag as the service key to match in the given container. Fields tagged with
+// `service:",type"` are instead matched by their type. If the field is a nested anonymous struct or
+// is tagged with `service:",inline"`, its fields are injected recursively. The given path is the dotted
+// field path to the field's parent. This function returns true if the field was updated.
+func (i *injector) injectField(f fieldPlan, fieldValue reflect.Value, path string) (bool, error) {
+	if f.err != nil {
+		return i.fieldError(path, f, nil, f.err)
 	}
 
-	fieldValue := (*root).FieldByIndex(indexPath)
-	serviceTag := fieldType.Tag.Get(serviceTag)
-	optionalTag := fieldType.Tag.Get(optionalTag)
+	if (f.anonymous || f.inline) && fieldValue.CanSet() {
+		skip, err := i.checkRecursive(f, fieldValue)
+		if err != nil {
+			return i.fieldError(path, f, nil, err)
+		}
+		if skip {
+			return false, nil
+		}
+	}
 
-	if serviceTag == "" {
-		return false, nil
+	if f.anonymous {
+		return i.injectNestedField(f, fieldValue, joinPath(path, f.field.Name))
 	}
 
-	optional := false
-	if optionalTag != "" {
-		val, err := strconv.ParseBool(optionalTag)
+	if f.inline {
+		if !fieldValue.CanSet() {
+			return i.fieldError(path, f, nil, fmt.Errorf("field '%s' can not be set - it may be unexported", f.field.Name))
+		}
+
+		return i.injectNestedField(f, fieldValue, joinPath(path, f.field.Name))
+	}
+
+	if f.group != "" {
+		updated, err := i.loadGroupField(f, fieldValue)
 		if err != nil {
-			return false, fmt.Errorf("field '%s' has an invalid optional tag", fieldType.Name)
+			return i.fieldError(path, f, nil, err)
+		}
+
+		return updated, nil
+	}
+
+	updated, err := i.loadServiceField(f, fieldValue)
+	if err != nil {
+		var errKey interface{} = f.key
+		if f.byType {
+			errKey = TypeKey(fieldValue.Type())
 		}
 
-		optional = val
+		return i.fieldError(path, f, errKey, err)
 	}
 
-	return loadServiceField(c, fieldType, fieldValue, serviceTag, optional)
+	return updated, nil
 }
 
-// injectAnonymousField sets the value of the given struct field to the recursively injected value
-// for this field. If the field is unset, a zero value of the field's type will be used as a base.
-// This function returns true if the struct field was updated.
-func injectAnonymousField(ctx context.Context, c *Container, fieldType reflect.StructField, root *reflect.Value, indexPath []int) (bool, error) {
-	fieldValue := (*root).FieldByIndex(indexPath)
+// checkRecursive checks an anonymous or inline field whose type is a pointer to a struct type that
+// is already being injected. A nil field would be allocated and injected indefinitely and is rejected,
+// unless its struct is linked (see nestedStruct): such a field ends the chain supplied by the caller
+// and is skipped. A non-nil field that refers to a value already being injected is also rejected.
+// Otherwise, the field is injected as part of the chain. This function returns true if the field
+// should be skipped.
+func (i *injector) checkRecursive(f fieldPlan, fieldValue reflect.Value) (bool, error) {
+	if fieldValue.Kind() != reflect.Ptr || !i.recursive(fieldValue.Type().Elem()) {
+		return false, nil
+	}
+
+	if fieldValue.IsNil() {
+		if i.structs[len(i.structs)-1].linked {
+			return true, nil
+		}
+
+		return false, invalidServiceTag(f.field, fmt.Sprintf("nil field of recursive type %s cannot be allocated", f.field.Type))
+	}
+
+	for _, s := range i.structs {
+		if s.addr == fieldValue.Pointer() {
+			return false, invalidServiceTag(f.field, fmt.Sprintf("field refers to a %s value that is already being injected", f.field.Type))
+		}
+	}
+
+	i.linked = true
+	return false, nil
+}
+
+// recursive returns true if the given struct type is already being injected.
+func (i *injector) recursive(t reflect.Type) bool {
+	for _, s := range i.structs {
+		if s.typ == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fieldError returns the given error, or records it and returns nil if errors are being collected.
+func (i *injector) fieldError(path string, f fieldPlan, key interface{}, err error) (bool, error) {
+	if !i.collect {
+		return false, err
+	}
+
+	i.errs = append(i.errs, &FieldError{Path: joinPath(path, f.field.Name), Key: key, Err: err})
+	return false, nil
+}
+
+// injectNestedField sets the value of the given anonymous or inline struct field to the recursively
+// injected value for this field. If the field is a nil pointer, a zero value of the field's type will
+// be used as a base. This function returns true if the struct field was updated.
+func (i *injector) injectNestedField(f fieldPlan, fieldValue reflect.Value, path string) (bool, error) {
 	if !fieldValue.CanSet() {
 		return false, nil
 	}
@@ -101,59 +247,113 @@ func injectAnonymousField(ctx context.Context, c *Container, fieldType reflect.S
 	wasZeroValue := false
 	if !reflect.Indirect(fieldValue).IsValid() {
 		wasZeroValue = true
-		initializedValue := reflect.New(fieldType.Type.Elem())
-		fieldValue.Set(initializedValue)
-		fieldValue = initializedValue
+		fieldValue.Set(reflect.New(f.field.Type.Elem()))
 	}
 
-	anonymousFieldHasTag, err := inject(ctx, c, fieldValue.Interface(), root, indexPath)
+	nestedFieldHasTag, err := i.inject(fieldValue.Interface(), fieldValue, path)
 	if err != nil {
 		return false, err
 	}
 
-	if !anonymousFieldHasTag && wasZeroValue {
-		zeroValue := reflect.Zero(fieldType.Type)
-		fieldValue = (*root).FieldByIndex(indexPath)
-		fieldValue.Set(zeroValue)
+	if !nestedFieldHasTag && wasZeroValue {
+		fieldValue.Set(reflect.Zero(f.field.Type))
 	}
 
 	return true, nil
 }
 
 // loadServiceField sets the value of the given struct field to the value of the service registered to
-// the given service key in the given container. This function returns true if the field was updated.
-func loadServiceField(c *Container, fieldType reflect.StructField, fieldValue reflect.Value, serviceTag string, optional bool) (bool, error) {
+// the field's service key in the given container. If the field is matched by type, the field is instead
+// populated by the single service assignable to the field's type (see Container.lookupType). If no
+// such service exists, the field's default key is used instead (see fieldTag). This function returns
+// true if the field was updated. During a dry run, the field is only checked.
+func (i *injector) loadServiceField(f fieldPlan, fieldValue reflect.Value) (bool, error) {
 	if !fieldValue.IsValid() {
-		return false, fmt.Errorf("field '%s' is invalid", fieldType.Name)
+		return false, fmt.Errorf("field '%s' is invalid", f.field.Name)
 	}
 
 	if !fieldValue.CanSet() {
-		return false, fmt.Errorf("field '%s' can not be set - it may be unexported", fieldType.Name)
+		return false, fmt.Errorf("field '%s' can not be set - it may be unexported", f.field.Name)
 	}
 
-	value, err := c.Get(serviceTag)
-	if err != nil {
-		if optional {
-			return false, nil
+	var (
+		e     *entry
+		owner *Container
+		ok    bool
+	)
+	if f.byType {
+		var err error
+		if e, owner, ok, err = i.c.lookupType(fieldValue.Type()); err != nil {
+			return false, err
+		}
+	} else {
+		e, owner, ok = i.c.lookup(f.key)
+	}
+	if !ok && f.fallback != "" {
+		e, owner, ok = i.c.lookup(f.fallback)
+	}
+	if !ok && !f.optional {
+		if f.byType {
+			return false, &NotFoundError{Type: fieldValue.Type()}
 		}
 
-		return false, err
+		return false, &NotFoundError{Key: f.key}
+	}
+	if !ok {
+		// Only a missing service is tolerated for optional fields; factory errors are still reported
+		return false, nil
 	}
 
+	strict := f.strict || i.c.options.strictTypes
 	targetType := fieldValue.Type()
+
+	if i.dryRun {
+		return false, checkServiceField(e, f.field, targetType, strict)
+	}
+
+	value, err := e.resolve(i.ctx, owner, i.c)
+	if err != nil {
+		return false, err
+	}
+
 	targetValue := reflect.ValueOf(value)
 
-	if !targetValue.IsValid() || !targetValue.Type().ConvertibleTo(targetType) {
-		var typeName string
-		if value == nil {
-			typeName = "nil"
-		} else {
-			typeName = reflect.TypeOf(value).String()
-		}
+	if !targetValue.IsValid() || !compatible(targetValue.Type(), targetType, strict) {
+		return false, &TypeMismatchError{Key: e.key, Field: f.field.Name, Want: targetType, Got: reflect.TypeOf(value), Strict: strict}
+	}
 
-		return false, fmt.Errorf("field '%s' cannot be assigned a value of type %s", fieldType.Name, typeName)
+	if strict {
+		fieldValue.Set(targetValue)
+	} else {
+		fieldValue.Set(targetValue.Convert(targetType))
 	}
 
-	fieldValue.Set(targetValue.Convert(targetType))
 	return true, nil
 }
+
+// checkServiceField returns an error if the service held by the given entry is known to be
+// incompatible with the given field type. Services constructed by factories that have not yet been
+// invoked are assumed to be compatible unless their type was declared via Provide.
+func checkServiceField(e *entry, fieldType reflect.StructField, targetType reflect.Type, strict bool) error {
+	typ := e.concreteType()
+	if typ == nil {
+		if e.factory != nil {
+			return nil
+		}
+	} else if compatible(typ, targetType, strict) {
+		return nil
+	}
+
+	return &TypeMismatchError{Key: e.key, Field: fieldType.Name, Want: targetType, Got: typ, Strict: strict}
+}
+
+// compatible returns true if a service of the given type can populate a field of the target type.
+// In strict mode, the service must be assignable to the field (which includes implementing the
+// field's interface type). Otherwise, any conversion permitted by the reflect package is allowed.
+func compatible(typ, targetType reflect.Type, strict bool) bool {
+	if strict {
+		return typ.AssignableTo(targetType)
+	}
+
+	return typ.ConvertibleTo(targetType)
+}
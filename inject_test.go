@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -195,3 +197,433 @@ func TestContainerUnsettableFields(t *testing.T) {
 	err := Inject(context.Background(), container, &T2{})
 	assert.EqualError(t, err, "field 'value' can not be set - it may be unexported")
 }
+
+func TestInjectByType(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value  *T1        `service:",type"`
+		Logger testLogger `service:",type"`
+	}
+
+	container := New()
+	container.Set("value", &T1{42})
+	container.Set("logger", &testLoggerImpl{"app"})
+	container.Set("other", "foo")
+	obj := &T2{}
+	err := Inject(context.Background(), container, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42, obj.Value.val)
+	assert.Equal(t, &testLoggerImpl{"app"}, obj.Logger)
+}
+
+func TestInjectByTypePrefersProvide(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:",type"`
+	}
+
+	container := New()
+	container.Set("a", &T1{10})
+	container.Set("b", &T1{20})
+	container.Provide(func() *T1 { return &T1{42} })
+	obj := &T2{}
+	err := Inject(context.Background(), container, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42, obj.Value.val)
+}
+
+func TestInjectByTypeOverlay(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:",type"`
+	}
+
+	container := New()
+	container.Set("value", &T1{10})
+	overlay, err := container.WithValues(map[interface{}]interface{}{"value": &T1{42}})
+	require.Nil(t, err)
+
+	// The root value is shadowed by the overlay and is not ambiguous
+	obj := &T2{}
+	err = Inject(context.Background(), overlay, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42, obj.Value.val)
+}
+
+func TestInjectByTypeAmbiguous(t *testing.T) {
+	type T2 struct {
+		Logger testLogger `service:",type"`
+	}
+
+	container := New()
+	container.Set("a", &testLoggerImpl{"a"})
+	container.Set("b", &testLoggerImpl{"b"})
+	err := Inject(context.Background(), container, &T2{})
+	assert.EqualError(t, err, `ambiguous service type service.testLogger: matched keys "a", "b"`)
+}
+
+func TestInjectByTypeMissing(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:",type"`
+	}
+	type T3 struct {
+		Value *T1 `service:",type" optional:"true"`
+	}
+
+	container := New()
+	err := Inject(context.Background(), container, &T2{})
+	assert.EqualError(t, err, "no service registered assignable to type *service.T1")
+
+	obj := &T3{}
+	err = Inject(context.Background(), container, obj)
+	require.Nil(t, err)
+	assert.Nil(t, obj.Value)
+}
+
+func TestInjectByTypeWithKey(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value,type"`
+	}
+
+	container := New()
+	err := Inject(context.Background(), container, &T2{})
+	assert.EqualError(t, err, "field 'Value' has an invalid service tag: option type cannot be combined with a service key")
+}
+
+func TestInjectAnonymousNotFirstField(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value"`
+	}
+	type T3 struct {
+		Other int
+		*T2
+	}
+
+	container := New()
+	container.Set("value", &T1{42})
+	obj := &T3{}
+	err := Inject(context.Background(), container, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42, obj.Value.val)
+}
+
+type testInjectAllLogger struct{}
+
+type Handlers struct {
+	Logger *testInjectAllLogger `service:"logger"`
+	Store  *testStore           `service:"store"`
+}
+
+type testInjectAllServer struct {
+	Handlers
+	Value    *TI     `service:"value"`
+	Config   *TF     `service:"config" optional:"yup"`
+	DB       *testDB `service:",type"`
+	injected bool
+}
+
+func (s *testInjectAllServer) PostInject(ctx context.Context) error {
+	s.injected = true
+	return nil
+}
+
+func TestInjectAll(t *testing.T) {
+	container := New()
+	container.Set("value", &TI{42})
+	container.Set("store", &TF{3.14})
+
+	obj := &testInjectAllServer{}
+	err := InjectAll(context.Background(), container, obj)
+	require.NotNil(t, err)
+
+	var injectionErr *InjectionError
+	require.True(t, errors.As(err, &injectionErr))
+	require.Len(t, injectionErr.Errors, 4)
+	assert.Equal(t, "testInjectAllServer.Handlers.Logger", injectionErr.Errors[0].Path)
+	assert.Equal(t, "logger", injectionErr.Errors[0].Key)
+	assert.Equal(t, "testInjectAllServer.Handlers.Store", injectionErr.Errors[1].Path)
+	assert.Equal(t, "testInjectAllServer.Config", injectionErr.Errors[2].Path)
+	assert.Nil(t, injectionErr.Errors[2].Key)
+	assert.Equal(t, "testInjectAllServer.DB", injectionErr.Errors[3].Path)
+	assert.Equal(t, TypeKey(reflect.TypeOf(&testDB{})), injectionErr.Errors[3].Key)
+
+	assert.EqualError(t, err, `4 fields could not be injected:
+	testInjectAllServer.Handlers.Logger ("logger"): no service registered to key "logger"
+	testInjectAllServer.Handlers.Store ("store"): field 'Store' cannot be assigned a value of type *service.TF
+	testInjectAllServer.Config: field 'Config' has an invalid optional tag
+	testInjectAllServer.DB (*service.testDB): no service registered assignable to type *service.testDB`)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+	assert.True(t, errors.Is(err, ErrInvalidTag))
+
+	// Successfully injected fields are still populated, but hooks are skipped
+	assert.Equal(t, 42, obj.Value.val)
+	assert.False(t, obj.injected)
+}
+
+func TestInjectAllSuccess(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value"`
+	}
+
+	container := New()
+	container.Set("value", &T1{42})
+	obj := &T2{}
+	require.Nil(t, InjectAll(context.Background(), container, obj))
+	assert.Equal(t, 42, obj.Value.val)
+}
+
+func TestInjectAllPostInject(t *testing.T) {
+	container := New()
+	container.Set("value", &TI{42})
+	obj := &testPostInjectProcess{}
+	require.Nil(t, InjectAll(context.Background(), container, obj))
+	assert.Equal(t, 42.0, obj.FValue.val)
+
+	err := InjectAll(context.Background(), New(), &testPostInjectProcessError{})
+	assert.EqualError(t, err, "oops")
+
+	err = InjectAll(context.Background(), New(), &testPostInjectProcess{})
+	assert.EqualError(t, err, "1 field could not be injected:\n\ttestPostInjectProcess.IValue (\"value\"): no service registered to key \"value\"")
+}
+
+func TestInjectLenientConversion(t *testing.T) {
+	type Celsius float64
+	type T struct {
+		Value float64 `service:"value"`
+		Temp  float64 `service:"temp"`
+	}
+
+	container := New()
+	container.Set("value", 42)
+	container.Set("temp", Celsius(21.5))
+	obj := &T{}
+	err := Inject(context.Background(), container, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42.0, obj.Value)
+	assert.Equal(t, 21.5, obj.Temp)
+}
+
+func TestInjectStrictTypes(t *testing.T) {
+	type Celsius float64
+	type T1 struct {
+		Value float64 `service:"value"`
+	}
+	type T2 struct {
+		Temp float64 `service:"temp"`
+	}
+	type T3 struct {
+		Logger testLogger `service:"logger"`
+		Temp   Celsius    `service:"temp"`
+	}
+
+	container := New(WithStrictTypes())
+	container.Set("value", 42)
+	container.Set("temp", Celsius(21.5))
+	container.Set("logger", &testLoggerImpl{})
+
+	err := Inject(context.Background(), container, &T1{})
+	assert.EqualError(t, err, `field 'Value' requires a value assignable to type float64, but service "value" has type int`)
+
+	var typeMismatchErr *TypeMismatchError
+	require.True(t, errors.As(err, &typeMismatchErr))
+	assert.True(t, typeMismatchErr.Strict)
+	assert.Equal(t, reflect.TypeOf(0.0), typeMismatchErr.Want)
+	assert.Equal(t, reflect.TypeOf(0), typeMismatchErr.Got)
+
+	err = Inject(context.Background(), container.NewScope(), &T2{})
+	assert.EqualError(t, err, `field 'Temp' requires a value assignable to type float64, but service "temp" has type service.Celsius`)
+
+	obj := &T3{}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	assert.Equal(t, &testLoggerImpl{}, obj.Logger)
+	assert.Equal(t, Celsius(21.5), obj.Temp)
+}
+
+func TestInjectStrictTag(t *testing.T) {
+	type T struct {
+		Lenient float64 `service:"value"`
+		Strict  float64 `service:"value,strict"`
+	}
+
+	container := New()
+	container.Set("value", 42)
+	obj := &T{}
+	err := Inject(context.Background(), container, obj)
+	assert.EqualError(t, err, `field 'Strict' requires a value assignable to type float64, but service "value" has type int`)
+	assert.Equal(t, 42.0, obj.Lenient)
+
+	err = container.Validate(obj)
+	assert.EqualError(t, err, "1 field could not be injected:\n\tT.Strict (\"value\"): field 'Strict' requires a value assignable to type float64, but service \"value\" has type int")
+}
+
+func TestInjectOptionalOption(t *testing.T) {
+	type T struct {
+		Value *TI `service:"value,optional"`
+	}
+
+	obj := &T{}
+	err := Inject(context.Background(), New(), obj)
+	require.Nil(t, err)
+	assert.Nil(t, obj.Value)
+}
+
+func TestInjectDefault(t *testing.T) {
+	type T struct {
+		Value  *TI        `service:"value,default=fallback"`
+		Logger testLogger `service:",type,default=logger"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("fallback", &TI{1}))
+	require.Nil(t, container.SetFactory("logger", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &testLoggerImpl{}, nil
+	}))
+
+	obj := &T{}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	assert.Equal(t, 1, obj.Value.val)
+	assert.NotNil(t, obj.Logger)
+
+	child := container.NewScope()
+	require.Nil(t, child.SetLocal("value", &TI{2}))
+
+	obj = &T{}
+	require.Nil(t, Inject(context.Background(), child, obj))
+	assert.Equal(t, 2, obj.Value.val)
+}
+
+func TestInjectDefaultMissing(t *testing.T) {
+	type T1 struct {
+		Value *TI `service:"value,default=fallback"`
+	}
+	type T2 struct {
+		Value *TI `service:"value,optional,default=fallback"`
+	}
+
+	container := New()
+	err := Inject(context.Background(), container, &T1{})
+	assert.EqualError(t, err, `no service registered to key "value"`)
+
+	obj := &T2{}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	assert.Nil(t, obj.Value)
+}
+
+func TestInjectInline(t *testing.T) {
+	type Deps struct {
+		Value *TI `service:"value"`
+	}
+	type T struct {
+		Deps     Deps  `service:",inline"`
+		Pointer  *Deps `service:",inline"`
+		Existing *Deps `service:",inline"`
+		Ignored  *Deps
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+
+	existing := &Deps{}
+	obj := &T{Existing: existing}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	assert.Equal(t, 42, obj.Deps.Value.val)
+	require.NotNil(t, obj.Pointer)
+	assert.Equal(t, 42, obj.Pointer.Value.val)
+	assert.Same(t, existing, obj.Existing)
+	assert.Equal(t, 42, existing.Value.val)
+	assert.Nil(t, obj.Ignored)
+}
+
+func TestInjectInlineNoServiceTags(t *testing.T) {
+	type Deps struct{ Value *TI }
+	type T struct {
+		Deps *Deps `service:",inline"`
+	}
+
+	obj := &T{}
+	require.Nil(t, Inject(context.Background(), New(), obj))
+	assert.Nil(t, obj.Deps)
+}
+
+func TestInjectInlineErrors(t *testing.T) {
+	type Deps struct {
+		Value *TI `service:"value"`
+	}
+	type T struct {
+		Deps    Deps  `service:",inline"`
+		private *Deps `service:",inline"`
+		Key     *Deps `service:"deps,inline"`
+		Scalar  int   `service:",inline"`
+	}
+
+	err := InjectAll(context.Background(), New(), &T{})
+	assert.EqualError(t, err, `4 fields could not be injected:
+	T.Deps.Value ("value"): no service registered to key "value"
+	T.private: field 'private' can not be set - it may be unexported
+	T.Key: field 'Key' has an invalid service tag: option inline cannot be combined with a service key or other options
+	T.Scalar: field 'Scalar' has an invalid service tag: option inline requires a struct or pointer to struct field`)
+}
+
+type testInlineNode struct {
+	Value *TI             `service:"value"`
+	Next  *testInlineNode `service:",inline"`
+}
+
+func TestInjectInlineRecursive(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+
+	obj := &testInlineNode{}
+	err := Inject(context.Background(), container, obj)
+	assert.EqualError(t, err, "field 'Next' has an invalid service tag: nil field of recursive type *service.testInlineNode cannot be allocated")
+
+	var invalidTagErr *InvalidTagError
+	require.True(t, errors.As(err, &invalidTagErr))
+	assert.Equal(t, "Next", invalidTagErr.Field)
+
+	err = container.Validate(&testInlineNode{})
+	assert.EqualError(t, err, `1 field could not be injected:
+	testInlineNode.Next: field 'Next' has an invalid service tag: nil field of recursive type *service.testInlineNode cannot be allocated`)
+
+	type Node struct {
+		*Node
+		Value *TI `service:"value"`
+	}
+
+	err = Inject(context.Background(), container, &Node{})
+	assert.True(t, errors.Is(err, ErrInvalidTag))
+}
+
+func TestInjectInlineRecursiveChain(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+
+	// Chains supplied by the caller are injected and end at the first nil field
+	obj := &testInlineNode{Next: &testInlineNode{Next: &testInlineNode{}}}
+	require.Nil(t, Inject(context.Background(), container, obj))
+	assert.Equal(t, &TI{42}, obj.Value)
+	assert.Equal(t, &TI{42}, obj.Next.Value)
+	assert.Equal(t, &TI{42}, obj.Next.Next.Value)
+	assert.Nil(t, obj.Next.Next.Next)
+
+	type Node struct {
+		*Node
+		Value *TI `service:"value"`
+	}
+
+	node := &Node{Node: &Node{}}
+	require.Nil(t, Inject(context.Background(), container, node))
+	assert.Equal(t, &TI{42}, node.Value)
+	assert.Equal(t, &TI{42}, node.Node.Value)
+
+	// Cyclic values are rejected
+	cyclic := &testInlineNode{}
+	cyclic.Next = cyclic
+	err := Inject(context.Background(), container, cyclic)
+	assert.EqualError(t, err, "field 'Next' has an invalid service tag: field refers to a *service.testInlineNode value that is already being injected")
+}
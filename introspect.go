@@ -0,0 +1,138 @@
+package service
+
+import "reflect"
+
+// Entry describes a single service registration visible from a container (see Entries).
+type Entry struct {
+	// Key is the key to which the service is registered.
+	Key interface{}
+
+	// Tag is the tag of the key, if any (see InjectableServiceKey).
+	Tag string
+
+	// Type is the concrete type of the service, or nil if the type is not yet known because the
+	// service has not been constructed by its factory.
+	Type reflect.Type
+
+	// Layer is the index of the container layer holding the service. Layer zero is the root container
+	// and each subsequent layer is an overlay created from the previous layer (see WithValues).
+	Layer int
+
+	// Shadowed is set if the service is hidden by a service with an equivalent key registered to a
+	// nearer layer (see SetLocal).
+	Shadowed bool
+
+	// Lifetime is the lifetime of a service registered with a factory. Services registered directly
+	// as a value are reported as singletons.
+	Lifetime Lifetime
+
+	// Value is the undecorated service, or nil if the service has not yet been constructed by its
+	// factory. Transient services are never reported with a value.
+	Value interface{}
+}
+
+// Has returns true if a service is registered to the given key (or a key with the same tag, see
+// InjectableServiceKey) in this container or one of its parent layers. Factories are not invoked.
+func (c *Container) Has(key interface{}) bool {
+	_, _, ok := c.lookup(key)
+	return ok
+}
+
+// Keys returns the keys of the services that can be retrieved from this container, ordered from
+// the root layer to the nearest layer and by registration order within each layer. Keys of shadowed
+// services are omitted.
+func (c *Container) Keys() []interface{} {
+	keys := []interface{}{}
+	for _, info := range c.Entries() {
+		if !info.Shadowed {
+			keys = append(keys, info.Key)
+		}
+	}
+
+	return keys
+}
+
+// Entries returns a description of each service registered to this container and its parent layers,
+// ordered from the root layer to the nearest layer and by registration order within each layer.
+// Services shadowed by a nearer layer are included and flagged. Factories are not invoked.
+func (c *Container) Entries() []Entry {
+	layers := c.layers()
+
+	entries := []Entry{}
+	for i, layer := range layers {
+		layer.mutex.RLock()
+		layerEntries := append([]*entry(nil), layer.entries...)
+		layer.mutex.RUnlock()
+
+		for _, e := range layerEntries {
+			info := Entry{
+				Key:      e.key,
+				Type:     e.concreteType(),
+				Layer:    i,
+				Shadowed: shadowed(e, layers[i+1:]),
+				Lifetime: e.lifetime,
+			}
+
+			if tag, ok := tagForKey(e.key); ok {
+				info.Tag = tag
+			}
+			if value, ok := c.builtValue(e); ok {
+				info.Value = value
+			}
+
+			entries = append(entries, info)
+		}
+	}
+
+	return entries
+}
+
+// layers returns this container and its parent layers, starting with the root container.
+func (c *Container) layers() []*Container {
+	var layers []*Container
+	for layer := c; layer != nil; layer = layer.parent {
+		layers = append([]*Container{layer}, layers...)
+	}
+
+	return layers
+}
+
+// shadowed returns true if a service with a key equivalent to the given entry's key is registered
+// to any of the given layers.
+func shadowed(e *entry, nearer []*Container) bool {
+	for _, layer := range nearer {
+		if _, ok := layer.lookupLocal(e.key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// builtValue returns the value of the given entry as seen from this container if it has already
+// been constructed. This method does not block on a factory that is being invoked concurrently.
+func (c *Container) builtValue(e *entry) (interface{}, bool) {
+	if e.factory == nil {
+		return e.instance.value, true
+	}
+
+	i := &e.instance
+	switch e.lifetime {
+	case Transient:
+		return nil, false
+	case Scoped:
+		scoped, ok := c.scoped.Load(e)
+		if !ok {
+			return nil, false
+		}
+
+		i = scoped.(*instance)
+	}
+
+	if !i.mutex.TryLock() {
+		return nil, false
+	}
+	defer i.mutex.Unlock()
+
+	return i.value, i.built
+}
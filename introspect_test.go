@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerHas(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set(testKey1{"a"}, &TI{10}))
+	require.Nil(t, container.SetFactory("b", func(ctx context.Context, c *Container) (interface{}, error) {
+		panic("factory invoked")
+	}))
+
+	overlay, err := container.WithValues(map[interface{}]interface{}{"c": &TI{30}})
+	require.Nil(t, err)
+
+	assert.True(t, container.Has("a"))
+	assert.True(t, container.Has(testKey2{"a"}))
+	assert.True(t, container.Has("b"))
+	assert.False(t, container.Has("c"))
+	assert.True(t, overlay.Has("a"))
+	assert.True(t, overlay.Has("c"))
+	assert.False(t, overlay.Has("d"))
+}
+
+func TestContainerKeys(t *testing.T) {
+	container := New()
+	assert.Empty(t, container.Keys())
+
+	require.Nil(t, container.Set("a", &TI{10}))
+	require.Nil(t, container.Set(testKey1{"b"}, &TI{20}))
+
+	scope := container.NewScope()
+	require.Nil(t, scope.SetLocal("c", &TI{30}))
+	require.Nil(t, scope.SetLocal(testKey2{"b"}, &TI{25}))
+
+	assert.Equal(t, []interface{}{"a", testKey1{"b"}}, container.Keys())
+	assert.Equal(t, []interface{}{"a", "c", testKey2{"b"}}, scope.Keys())
+}
+
+func TestContainerEntries(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+	require.Nil(t, container.SetFactory("b", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{20}, nil
+	}))
+	require.Nil(t, container.SetTransient("c", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{30}, nil
+	}))
+	require.Nil(t, container.SetScoped("d", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{40}, nil
+	}))
+	require.Nil(t, container.Provide(func() *testDB { return &testDB{} }))
+
+	scope := container.NewScope()
+	require.Nil(t, scope.SetLocal("a", &TI{15}))
+
+	entries := scope.Entries()
+	require.Len(t, entries, 6)
+	tiType := reflect.TypeOf(&TI{})
+	dbType := reflect.TypeOf(&testDB{})
+
+	assert.Equal(t, Entry{Key: "a", Tag: "a", Type: tiType, Layer: 0, Shadowed: true, Lifetime: Singleton, Value: &TI{10}}, entries[0])
+	assert.Equal(t, Entry{Key: "b", Tag: "b", Layer: 0, Lifetime: Singleton}, entries[1])
+	assert.Equal(t, Entry{Key: "c", Tag: "c", Layer: 0, Lifetime: Transient}, entries[2])
+	assert.Equal(t, Entry{Key: "d", Tag: "d", Layer: 0, Lifetime: Scoped}, entries[3])
+	assert.Equal(t, Entry{Key: TypeKey(dbType), Type: dbType, Layer: 0, Lifetime: Singleton}, entries[4])
+	assert.Equal(t, Entry{Key: "a", Tag: "a", Type: tiType, Layer: 1, Lifetime: Singleton, Value: &TI{15}}, entries[5])
+
+	// Constructed services are reported with their value
+	for _, key := range []interface{}{"b", "c", "d"} {
+		_, err := scope.Get(key)
+		require.Nil(t, err)
+	}
+
+	entries = scope.Entries()
+	assert.Equal(t, Entry{Key: "b", Tag: "b", Type: tiType, Layer: 0, Lifetime: Singleton, Value: &TI{20}}, entries[1])
+	assert.Equal(t, Entry{Key: "c", Tag: "c", Layer: 0, Lifetime: Transient}, entries[2])
+	assert.Equal(t, Entry{Key: "d", Tag: "d", Layer: 0, Lifetime: Scoped, Value: &TI{40}}, entries[3])
+
+	// Scoped values are reported for the container from which they were retrieved
+	assert.Nil(t, container.Entries()[3].Value)
+}
@@ -16,6 +16,10 @@ type InjectableServiceKey interface {
 // prettyKey returns a human-readable string describing the given
 // service key.
 func prettyKey(key interface{}) string {
+	if k, ok := key.(typeKey); ok {
+		return k.t.String()
+	}
+
 	if tag, ok := tagForKey(key); ok {
 		if _, ok := key.(string); ok {
 			return fmt.Sprintf(`"%s"`, key)
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	starterType = reflect.TypeOf((*Starter)(nil)).Elem()
+	stopperType = reflect.TypeOf((*Stopper)(nil)).Elem()
+)
+
+// Starter is an interface for services that should be started by Container.Start.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is an interface for services that should be stopped by Container.Stop.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// lifecycleService is a service managed by Container.Start and Container.Stop.
+type lifecycleService struct {
+	key   interface{}
+	value interface{}
+}
+
+// Start starts the services registered to this container layer that implement Starter. Services
+// are started in dependency order (see Graph), so that a service is started only after all of the
+// services on which it depends. Every singleton factory registered to this layer is invoked to
+// determine whether its service implements Starter or Stopper, except for constructors registered
+// via Provide whose declared type is a concrete type implementing neither. Transient and scoped
+// services are not managed by the container. If a service fails to start, the services that have
+// already started are stopped in reverse order and the resulting errors are returned together. A
+// service that does not start within the lifecycle timeout (see WithLifecycleTimeout) is given a
+// canceled context, and the rollback waits for its Start method to return before stopping the
+// services on which it depends.
+func (c *Container) Start(ctx context.Context) error {
+	c.mutex.Lock()
+	if c.starting {
+		c.mutex.Unlock()
+		return fmt.Errorf("container already started")
+	}
+	c.starting = true
+	c.mutex.Unlock()
+
+	services, err := c.lifecycleServices(ctx)
+	if err != nil {
+		c.setStarted(nil, false)
+		return err
+	}
+
+	started := make([]lifecycleService, 0, len(services))
+	for _, service := range services {
+		if starter, ok := service.value.(Starter); ok {
+			if done, err := c.callWithTimeout(ctx, starter.Start); err != nil {
+				// Do not stop dependencies while the service is still starting
+				<-done
+
+				errs := []error{fmt.Errorf("failed to start service %s: %w", prettyKey(service.key), err)}
+				errs = append(errs, c.stop(ctx, started)...)
+				c.setStarted(nil, false)
+				return errors.Join(errs...)
+			}
+		}
+
+		started = append(started, service)
+	}
+
+	c.setStarted(started, true)
+	return nil
+}
+
+// Stop stops the services started by the previous call to Start that implement Stopper, in the
+// reverse order in which they were started. Every service is stopped even if another service fails
+// to stop, and the resulting errors are returned together.
+func (c *Container) Stop(ctx context.Context) error {
+	c.mutex.Lock()
+	started := c.started
+	c.started = nil
+	c.starting = false
+	c.mutex.Unlock()
+
+	return errors.Join(c.stop(ctx, started)...)
+}
+
+func (c *Container) setStarted(started []lifecycleService, starting bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.started = started
+	c.starting = starting
+}
+
+// lifecycleServices resolves the singleton services registered to this container layer that may
+// implement Starter or Stopper and returns them in dependency order.
+func (c *Container) lifecycleServices(ctx context.Context) ([]lifecycleService, error) {
+	c.mutex.RLock()
+	entries := append([]*entry(nil), c.entries...)
+	c.mutex.RUnlock()
+
+	keys := make([]interface{}, 0, len(entries))
+	values := make(map[interface{}]interface{}, len(entries))
+	for _, e := range entries {
+		if e.factory != nil && (e.lifetime != Singleton || !mayHaveLifecycle(e.typ)) {
+			continue
+		}
+
+		value, err := e.resolve(ctx, c, c)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, e.key)
+		values[e.key] = value
+	}
+
+	keys, err := c.Graph().sort(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]lifecycleService, 0, len(keys))
+	for _, key := range keys {
+		services = append(services, lifecycleService{key: key, value: values[key]})
+	}
+
+	return services, nil
+}
+
+// mayHaveLifecycle returns false if values of the given type cannot implement Starter or Stopper.
+// A nil type is unknown and may have any value.
+func mayHaveLifecycle(t reflect.Type) bool {
+	if t == nil || t.Kind() == reflect.Interface {
+		return true
+	}
+
+	return t.Implements(starterType) || t.Implements(stopperType)
+}
+
+// stop stops the given services that implement Stopper in reverse order and returns any errors.
+func (c *Container) stop(ctx context.Context, services []lifecycleService) (errs []error) {
+	for i := len(services) - 1; i >= 0; i-- {
+		if stopper, ok := services[i].value.(Stopper); ok {
+			if _, err := c.callWithTimeout(ctx, stopper.Stop); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop service %s: %w", prettyKey(services[i].key), err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// callWithTimeout invokes the given function, returning early if the given context is canceled
+// or the container's lifecycle timeout elapses before the function returns. The function keeps
+// running with a canceled context after an early return. The returned channel is closed once the
+// function has returned (or immediately if it was never invoked).
+func (c *Container) callWithTimeout(ctx context.Context, f func(ctx context.Context) error) (<-chan struct{}, error) {
+	if c.options.lifecycleTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.lifecycleTimeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	if err := ctx.Err(); err != nil {
+		close(done)
+		return done, err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(done)
+		errs <- f(ctx)
+	}()
+
+	select {
+	case err := <-errs:
+		return done, err
+	case <-ctx.Done():
+		return done, ctx.Err()
+	}
+}
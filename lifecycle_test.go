@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLifecycleService struct {
+	name     string
+	events   *[]string
+	startErr error
+	stopErr  error
+	delay    time.Duration
+}
+
+func (s *testLifecycleService) Start(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	*s.events = append(*s.events, "start "+s.name)
+	return s.startErr
+}
+
+func (s *testLifecycleService) Stop(ctx context.Context) error {
+	*s.events = append(*s.events, "stop "+s.name)
+	return s.stopErr
+}
+
+type testLifecycleServer struct {
+	testLifecycleService
+	Store *testLifecycleService `service:"store"`
+}
+
+func TestContainerStartAndStop(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.Set("server", &testLifecycleServer{testLifecycleService: testLifecycleService{name: "server", events: &events}}))
+	require.Nil(t, container.SetFactory("store", func(ctx context.Context, c *Container) (interface{}, error) {
+		if _, err := c.GetContext(ctx, "db"); err != nil {
+			return nil, err
+		}
+
+		return &testLifecycleService{name: "store", events: &events}, nil
+	}))
+	require.Nil(t, container.Set("db", &testLifecycleService{name: "db", events: &events}))
+	require.Nil(t, container.Set("config", struct{}{}))
+	require.Nil(t, container.SetTransient("request", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &testLifecycleService{name: "request", events: &events}, nil
+	}))
+
+	require.Nil(t, container.Start(context.Background()))
+	assert.Equal(t, []string{"start db", "start store", "start server"}, events)
+
+	events = nil
+	require.Nil(t, container.Stop(context.Background()))
+	assert.Equal(t, []string{"stop server", "stop store", "stop db"}, events)
+}
+
+func TestContainerStartTwice(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Start(context.Background()))
+	assert.EqualError(t, container.Start(context.Background()), "container already started")
+
+	require.Nil(t, container.Stop(context.Background()))
+	require.Nil(t, container.Start(context.Background()))
+}
+
+func TestContainerStartError(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.Set("a", &testLifecycleService{name: "a", events: &events}))
+	require.Nil(t, container.Set("b", &testLifecycleService{name: "b", events: &events, stopErr: fmt.Errorf("oops")}))
+	require.Nil(t, container.Set("c", &testLifecycleService{name: "c", events: &events, startErr: fmt.Errorf("oops")}))
+	require.Nil(t, container.Set("d", &testLifecycleService{name: "d", events: &events}))
+
+	err := container.Start(context.Background())
+	assert.EqualError(t, err, "failed to start service \"c\": oops\nfailed to stop service \"b\": oops")
+	assert.Equal(t, []string{"start a", "start b", "start c", "stop b", "stop a"}, events)
+
+	// Services stopped during rollback are not stopped again
+	events = nil
+	require.Nil(t, container.Stop(context.Background()))
+	assert.Empty(t, events)
+}
+
+func TestContainerStopErrors(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.Set("a", &testLifecycleService{name: "a", events: &events, stopErr: fmt.Errorf("oops a")}))
+	require.Nil(t, container.Set("b", &testLifecycleService{name: "b", events: &events, stopErr: fmt.Errorf("oops b")}))
+
+	require.Nil(t, container.Start(context.Background()))
+	err := container.Stop(context.Background())
+	assert.EqualError(t, err, "failed to stop service \"b\": oops b\nfailed to stop service \"a\": oops a")
+	assert.Equal(t, []string{"start a", "start b", "stop b", "stop a"}, events)
+}
+
+func TestContainerStartTimeout(t *testing.T) {
+	var events []string
+	container := New(WithLifecycleTimeout(10 * time.Millisecond))
+	require.Nil(t, container.Set("a", &testLifecycleService{name: "a", events: &events, delay: time.Second}))
+
+	err := container.Start(context.Background())
+	assert.EqualError(t, err, "failed to start service \"a\": context deadline exceeded")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestContainerStartCanceled(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.Set("a", &testLifecycleService{name: "a", events: &events}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := container.Start(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, events)
+}
+
+func TestContainerStartCycle(t *testing.T) {
+	type A struct {
+		B interface{} `service:"b"`
+	}
+	type B struct {
+		A interface{} `service:"a"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("a", &A{}))
+	require.Nil(t, container.Set("b", &B{}))
+
+	assert.EqualError(t, container.Start(context.Background()), `dependency cycle detected: "a" -> "b" -> "a"`)
+}
+
+type testSlowStarter struct {
+	events *[]string
+}
+
+func (s *testSlowStarter) Start(ctx context.Context) error {
+	// Ignore cancellation of the context
+	time.Sleep(50 * time.Millisecond)
+	*s.events = append(*s.events, "start slow returned")
+	return nil
+}
+
+func TestContainerStartTimeoutRollback(t *testing.T) {
+	var events []string
+	container := New(WithLifecycleTimeout(10 * time.Millisecond))
+	require.Nil(t, container.Set("a", &testLifecycleService{name: "a", events: &events}))
+	require.Nil(t, container.Set("slow", &testSlowStarter{events: &events}))
+
+	err := container.Start(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, []string{"start a", "start slow returned", "stop a"}, events)
+}
+
+func TestContainerStartSkipsProvidedTypes(t *testing.T) {
+	var events []string
+	container := New()
+	require.Nil(t, container.Provide(func() int {
+		events = append(events, "construct int")
+		return 42
+	}))
+	require.Nil(t, container.Provide(func() *testLifecycleService {
+		events = append(events, "construct service")
+		return &testLifecycleService{name: "service", events: &events}
+	}))
+	require.Nil(t, container.SetFactory("factory", func(ctx context.Context, c *Container) (interface{}, error) {
+		events = append(events, "construct factory")
+		return 25, nil
+	}))
+
+	// Constructors declaring a type that implements neither Starter nor Stopper are not invoked
+	require.Nil(t, container.Start(context.Background()))
+	assert.Equal(t, []string{"construct service", "construct factory", "start service"}, events)
+}
@@ -0,0 +1,47 @@
+package service
+
+import "time"
+
+// Option configures a container created via New. Containers created via WithValues or NewScope
+// inherit the options of the container from which they were created.
+type Option func(*options)
+
+type options struct {
+	lifecycleTimeout time.Duration
+	shadowPolicy     ShadowPolicy
+	strictTypes      bool
+}
+
+// WithLifecycleTimeout limits the duration of each individual Start, Stop, and Close call made on
+// a service by Container.Start, Container.Stop, and Container.Close. A zero duration (the default)
+// imposes no limit beyond the deadline of the context passed to the container.
+func WithLifecycleTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.lifecycleTimeout = timeout }
+}
+
+// ShadowPolicy controls whether a service registered to a container layer via SetLocal may shadow
+// a service registered to one of the layer's parents.
+type ShadowPolicy int
+
+const (
+	// ShadowAllow permits local services to shadow services of parent layers. This is the default.
+	ShadowAllow ShadowPolicy = iota
+
+	// ShadowDeny rejects local services whose key (or tag) is registered to a parent layer.
+	ShadowDeny
+)
+
+// WithShadowPolicy sets the policy applied by SetLocal when a service is registered to a container
+// layer whose parent already holds a service with an equivalent key. The policy does not apply to
+// the values given to WithValues.
+func WithShadowPolicy(policy ShadowPolicy) Option {
+	return func(o *options) { o.shadowPolicy = policy }
+}
+
+// WithStrictTypes requires that every injected service be assignable to the field it populates (or
+// implement the field's interface type). By default, any value convertible to the field's type is
+// accepted, which permits conversions such as int to float64 or a named type to its underlying
+// type. Individual fields may opt in to strict checking with the `service:"key,strict"` tag.
+func WithStrictTypes() Option {
+	return func(o *options) { o.strictTypes = true }
+}
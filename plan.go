@@ -0,0 +1,59 @@
+package service
+
+import (
+	"reflect"
+	"sync"
+)
+
+// injectionPlan is the precompiled description of how to inject a struct type. Plans are computed
+// once per type and shared by every subsequent injection of that type.
+type injectionPlan struct {
+	fields []fieldPlan
+}
+
+// fieldPlan describes a single struct field that participates in injection: either an anonymous or
+// inline field whose own fields are injected recursively, or a field with a service tag.
+type fieldPlan struct {
+	fieldTag
+	index     int
+	field     reflect.StructField
+	anonymous bool
+
+	// err is set when the field's tags are malformed and is reported when the field is injected
+	err error
+}
+
+// plans caches the injection plan of each struct type.
+var plans sync.Map // map[reflect.Type]*injectionPlan
+
+// planFor returns the injection plan for the given struct type.
+func planFor(t reflect.Type) *injectionPlan {
+	if plan, ok := plans.Load(t); ok {
+		return plan.(*injectionPlan)
+	}
+
+	plan, _ := plans.LoadOrStore(t, compilePlan(t))
+	return plan.(*injectionPlan)
+}
+
+// compilePlan computes the injection plan for the given struct type. Fields that are neither
+// anonymous nor tagged for injection are omitted from the plan.
+func compilePlan(t reflect.Type) *injectionPlan {
+	plan := &injectionPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			plan.fields = append(plan.fields, fieldPlan{index: i, field: field, anonymous: true})
+			continue
+		}
+
+		tag, ok, err := parseFieldTag(field)
+		if !ok {
+			continue
+		}
+
+		plan.fields = append(plan.fields, fieldPlan{fieldTag: tag, index: i, field: field, err: err})
+	}
+
+	return plan
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePlan(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value"`
+	}
+	type T3 struct {
+		*T2
+		Untagged int
+		Logger   testLogger `service:",type" optional:"true"`
+		Bad      *T1        `service:"bad" optional:"yup"`
+	}
+
+	plan := compilePlan(reflect.TypeOf(T3{}))
+	require.Len(t, plan.fields, 3)
+
+	assert.Equal(t, 0, plan.fields[0].index)
+	assert.True(t, plan.fields[0].anonymous)
+
+	assert.Equal(t, 2, plan.fields[1].index)
+	assert.Equal(t, "", plan.fields[1].key)
+	assert.True(t, plan.fields[1].byType)
+	assert.True(t, plan.fields[1].optional)
+	assert.Nil(t, plan.fields[1].err)
+
+	assert.Equal(t, 3, plan.fields[2].index)
+	assert.Equal(t, "bad", plan.fields[2].key)
+	assert.EqualError(t, plan.fields[2].err, "field 'Bad' has an invalid optional tag")
+}
+
+func TestPlanForCached(t *testing.T) {
+	type T struct {
+		Value *TI `service:"value"`
+	}
+
+	plan := planFor(reflect.TypeOf(T{}))
+	assert.Same(t, plan, planFor(reflect.TypeOf(T{})))
+}
+
+func TestInjectConcurrent(t *testing.T) {
+	type T struct {
+		Value  *TI        `service:"value"`
+		Logger testLogger `service:",type"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set("logger", &testLoggerImpl{}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Inject(context.Background(), container, &T{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+}
+
+type benchmarkHandler struct {
+	benchmarkEmbedded
+	Value   *TI        `service:"value"`
+	Logger  testLogger `service:"logger"`
+	Store   *testStore `service:"store" optional:"true"`
+	Config  *TF        `service:"config" optional:"false"`
+	Request string
+}
+
+type benchmarkEmbedded struct {
+	DB *testDB `service:"db"`
+}
+
+func benchmarkInjectContainer(b *testing.B) *Container {
+	container := New()
+	require.Nil(b, container.Set("value", &TI{42}))
+	require.Nil(b, container.Set("logger", &testLoggerImpl{}))
+	require.Nil(b, container.Set("store", &testStore{}))
+	require.Nil(b, container.Set("config", &TF{3.14}))
+	require.Nil(b, container.Set("db", &testDB{}))
+	return container
+}
+
+func BenchmarkInject(b *testing.B) {
+	container := benchmarkInjectContainer(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Inject(ctx, container, &benchmarkHandler{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkInjectUncachedPlan measures injection when the plan is recompiled on every call, which
+// approximates the cost of reflecting over fields and parsing tags on every injection.
+func BenchmarkInjectUncachedPlan(b *testing.B) {
+	container := benchmarkInjectContainer(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		plans.Delete(reflect.TypeOf(benchmarkHandler{}))
+		plans.Delete(reflect.TypeOf(benchmarkEmbedded{}))
+
+		if err := Inject(ctx, container, &benchmarkHandler{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+// Seal prevents further modification of this container layer. Once sealed, registering services
+// (via Set, SetLocal, SetFactory, Provide, AddToGroup, and similar methods), replacing or removing
+// services, and registering decorators return ErrSealed. This includes modifications made through
+// a container created via WithValues or NewScope that would delegate to this container. Services can
+// still be retrieved from a sealed container, and overlays created from it may register services and
+// decorators of their own via SetLocal and Decorate. Sealing a sealed container has no effect.
+//
+// Services registered to a sealed container are looked up and, once constructed and decorated,
+// retrieved without acquiring its lock, which reduces contention when many goroutines retrieve
+// services concurrently. Constructing a service for the first time still acquires the lock.
+func (c *Container) Seal() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.sealed {
+		return
+	}
+
+	c.sealed = true
+	c.snapshot.Store(&snapshot{
+		services:   c.services,
+		keysByTag:  c.keysByTag,
+		entries:    c.entries,
+		decorators: c.decorators,
+	})
+}
+
+// isSealed returns true if Seal has been called on this container.
+func (c *Container) isSealed() bool {
+	return c.snapshot.Load() != nil
+}
+
+// snapshot holds the registrations of a sealed container layer. The maps and slices are shared
+// with the container, which never modifies them once sealed, so they may be read without holding
+// the container's lock.
+type snapshot struct {
+	services   map[interface{}]*entry
+	keysByTag  map[string]interface{}
+	entries    []*entry
+	decorators map[interface{}][]Decorator
+}
+
+// localEntries returns the entries registered to this container layer in registration order. The
+// lock of a sealed container is not acquired.
+func (c *Container) localEntries() []*entry {
+	if s := c.snapshot.Load(); s != nil {
+		return s.entries
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]*entry(nil), c.entries...)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeal(t *testing.T) {
+	factory := func(ctx context.Context, c *Container) (interface{}, error) { return &TI{}, nil }
+
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+	container.Seal()
+	container.Seal()
+
+	assert.Equal(t, ErrSealed, container.Set("b", &TI{20}))
+	assert.Equal(t, ErrSealed, container.SetLocal("b", &TI{20}))
+	assert.Equal(t, ErrSealed, container.SetOwned("b", &TI{20}))
+	assert.Equal(t, ErrSealed, container.SetFactory("b", factory))
+	assert.Equal(t, ErrSealed, container.SetTransient("b", factory))
+	assert.Equal(t, ErrSealed, container.SetScoped("b", factory))
+	assert.Equal(t, ErrSealed, container.Provide(func() *testDB { return &testDB{} }))
+	assert.Equal(t, ErrSealed, container.AddToGroup("group", "b", &TI{20}))
+	assert.Equal(t, ErrSealed, container.Replace("a", &TI{15}))
+	assert.Equal(t, ErrSealed, container.Remove("a"))
+	assert.Equal(t, ErrSealed, container.Decorate("a", wrapWith("metrics")))
+	assert.Equal(t, []interface{}{"a"}, container.Keys())
+
+	// Reads are unaffected
+	assertValue(t, container, "a", &TI{10})
+}
+
+func TestSealOverlay(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+	container.Seal()
+
+	overlay, err := container.WithValues(map[interface{}]interface{}{"b": &TI{20}})
+	require.Nil(t, err)
+	assertValue(t, overlay, "b", &TI{20})
+
+	// Modifications that delegate to the sealed container are rejected
+	assert.Equal(t, ErrSealed, overlay.Set("c", &TI{30}))
+	assert.Equal(t, ErrSealed, overlay.Replace("a", &TI{15}))
+	assert.Equal(t, ErrSealed, overlay.Remove("a"))
+
+	// Modifications local to the overlay are allowed
+	require.Nil(t, overlay.SetLocal("c", &TI{30}))
+	require.Nil(t, overlay.Replace("b", &TI{25}))
+	require.Nil(t, overlay.Decorate("a", func(ctx context.Context, service interface{}) (interface{}, error) {
+		return &TI{service.(*TI).val + 1}, nil
+	}))
+	assertValue(t, overlay, "a", &TI{11})
+	assertValue(t, overlay, "b", &TI{25})
+	assertValue(t, overlay, "c", &TI{30})
+	assertValue(t, container, "a", &TI{10})
+}
+
+func TestSealScope(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+
+	scope := container.NewScope()
+	scope.Seal()
+
+	// A sealed overlay does not modify its parent
+	assert.Equal(t, ErrSealed, scope.Set("b", &TI{20}))
+	assert.Equal(t, ErrSealed, scope.Replace("a", &TI{15}))
+	assert.Equal(t, ErrSealed, scope.Remove("a"))
+	assert.False(t, container.Has("b"))
+
+	require.Nil(t, container.Replace("a", &TI{15}))
+	assertValue(t, scope, "a", &TI{15})
+}
+
+func TestSealConcurrentReads(t *testing.T) {
+	type T struct {
+		Value  *TI        `service:"value"`
+		Logger testLogger `service:",type"`
+		Lazy   *TI        `service:"lazy"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set("logger", &testLoggerImpl{}))
+	require.Nil(t, container.SetFactory("lazy", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{25}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				if i == 0 && j == 50 {
+					// Seal while other goroutines are reading
+					container.Seal()
+				}
+
+				scope := container.NewScope()
+				assert.Nil(t, scope.SetLocal("local", &TI{j}))
+
+				obj := &T{}
+				assert.Nil(t, Inject(context.Background(), scope, obj))
+				assert.Equal(t, 42, obj.Value.val)
+				assert.Equal(t, 25, obj.Lazy.val)
+
+				value, err := scope.Get("local")
+				assert.Nil(t, err)
+				assert.Equal(t, &TI{j}, value)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, ErrSealed, container.Set("other", &TI{}))
+}
+
+func TestSealReadsWithoutLock(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("value", "db"))
+	require.Nil(t, container.SetFactory("lazy", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{1}, nil
+	}))
+	require.Nil(t, container.SetScoped("scoped", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{2}, nil
+	}))
+	require.Nil(t, container.Decorate("value", wrapWith("metrics")))
+	container.Seal()
+
+	// Construct services and populate caches
+	for _, key := range []string{"value", "lazy", "scoped"} {
+		_, err := container.Get(key)
+		require.Nil(t, err)
+	}
+
+	container.mutex.Lock()
+	defer container.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for _, key := range []string{"value", "lazy", "scoped"} {
+			_, err := container.Get(key)
+			assert.Nil(t, err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("retrieval from sealed container blocked on its lock")
+	}
+}
+
+func benchmarkGetContainer(b *testing.B, seal bool) *Container {
+	container := New()
+	for i := 0; i < 32; i++ {
+		if err := container.Set(fmt.Sprintf("value%d", i), &TI{i}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := container.SetFactory("lazy", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{}, nil
+	}); err != nil {
+		b.Fatal(err)
+	}
+
+	if seal {
+		container.Seal()
+	}
+
+	// Retrieve through a request scope, as a server handler would
+	return container.NewScope()
+}
+
+func benchmarkGet(b *testing.B, seal bool) {
+	container := benchmarkGetContainer(b, seal)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := container.Get("value16"); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := container.Get("lazy"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetParallel(b *testing.B)       { benchmarkGet(b, false) }
+func BenchmarkGetParallelSealed(b *testing.B) { benchmarkGet(b, true) }
+
+func benchmarkGetDecorated(b *testing.B, seal bool) {
+	container := New()
+	if err := container.Set("value", &TI{}); err != nil {
+		b.Fatal(err)
+	}
+	if err := container.SetScoped("scoped", func(ctx context.Context, c *Container) (interface{}, error) {
+		return &TI{}, nil
+	}); err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range []string{"value", "scoped"} {
+		if err := container.Decorate(key, func(ctx context.Context, service interface{}) (interface{}, error) {
+			return []*TI{service.(*TI)}, nil
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if seal {
+		container.Seal()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := container.Get("value"); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := container.Get("scoped"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetDecoratedParallel(b *testing.B)       { benchmarkGetDecorated(b, false) }
+func BenchmarkGetDecoratedParallelSealed(b *testing.B) { benchmarkGetDecorated(b, true) }
+
+func benchmarkInjectSealed(b *testing.B, seal bool) {
+	container := benchmarkInjectContainer(b)
+	if seal {
+		container.Seal()
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := Inject(ctx, container, &benchmarkHandler{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkInjectParallel(b *testing.B)       { benchmarkInjectSealed(b, false) }
+func BenchmarkInjectParallelSealed(b *testing.B) { benchmarkInjectSealed(b, true) }
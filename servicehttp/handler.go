@@ -0,0 +1,221 @@
+// Package servicehttp provides an HTTP handler that describes the contents of a service container
+// for diagnostic purposes.
+package servicehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/sourcegraph-testing/nacelle-service/v5"
+)
+
+// Redactor returns the text displayed in place of the value of the given service. The returned
+// flag is false if the value should be displayed as is.
+type Redactor func(entry service.Entry) (string, bool)
+
+// Option configures a Handler.
+type Option func(*options)
+
+type options struct {
+	showValues bool
+	redactor   Redactor
+}
+
+// ShowValues displays the value of each service that has been constructed. By default, service
+// values are not displayed, as they may contain secrets. Use WithRedactor to hide the values of
+// sensitive services.
+func ShowValues() Option {
+	return func(o *options) { o.showValues = true }
+}
+
+// WithRedactor sets the function used to hide the values of sensitive services when values are
+// displayed (see ShowValues). If the redactor returns an empty string, the value is displayed as
+// "[redacted]".
+func WithRedactor(redactor Redactor) Option {
+	return func(o *options) { o.redactor = redactor }
+}
+
+// Handler is an http.Handler that describes the services registered to a container and its parent
+// layers, along with the dependencies between them (see service.Container.Entries and
+// service.Container.Graph). Service values are omitted unless ShowValues is given. The description
+// is rendered as JSON if the request has the query parameter format=json or accepts
+// application/json, and as HTML otherwise.
+type Handler struct {
+	container *service.Container
+	options   options
+}
+
+// NewHandler creates a handler describing the given container.
+func NewHandler(container *service.Container, opts ...Option) *Handler {
+	h := &Handler{container: container}
+	for _, opt := range opts {
+		opt(&h.options)
+	}
+
+	return h
+}
+
+// document is the serialized description of a container.
+type document struct {
+	Layers   int            `json:"layers"`
+	Services []serviceEntry `json:"services"`
+	Edges    []edge         `json:"edges"`
+}
+
+// serviceEntry is the serialized description of a single service registration.
+type serviceEntry struct {
+	Key      string `json:"key"`
+	Tag      string `json:"tag,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Layer    int    `json:"layer"`
+	Shadowed bool   `json:"shadowed,omitempty"`
+	Lifetime string `json:"lifetime"`
+	Value    string `json:"value,omitempty"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// edge is the serialized description of a dependency of one service on another.
+type edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ServeHTTP writes a description of the container.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	doc := h.describe()
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(doc)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = page.Execute(w, doc)
+}
+
+// describe builds a description of the container.
+func (h *Handler) describe() document {
+	doc := document{
+		Services: []serviceEntry{},
+		Edges:    []edge{},
+	}
+
+	for _, entry := range h.container.Entries() {
+		if entry.Layer+1 > doc.Layers {
+			doc.Layers = entry.Layer + 1
+		}
+
+		doc.Services = append(doc.Services, h.describeEntry(entry))
+	}
+
+	graph := h.container.Graph()
+	for _, key := range graph.Keys() {
+		for _, dependency := range graph.Dependencies(key) {
+			doc.Edges = append(doc.Edges, edge{From: formatKey(key), To: formatKey(dependency)})
+		}
+	}
+
+	return doc
+}
+
+func (h *Handler) describeEntry(entry service.Entry) serviceEntry {
+	s := serviceEntry{
+		Key:      formatKey(entry.Key),
+		Tag:      entry.Tag,
+		Layer:    entry.Layer,
+		Shadowed: entry.Shadowed,
+		Lifetime: entry.Lifetime.String(),
+	}
+
+	if entry.Type != nil {
+		s.Type = entry.Type.String()
+	}
+
+	if !h.options.showValues {
+		return s
+	}
+
+	if h.options.redactor != nil {
+		if text, ok := h.options.redactor(entry); ok {
+			if text == "" {
+				text = "[redacted]"
+			}
+
+			s.Value = text
+			s.Redacted = true
+			return s
+		}
+	}
+	if entry.Value != nil {
+		s.Value = fmt.Sprintf("%+v", entry.Value)
+	}
+
+	return s
+}
+
+// formatKey returns a human-readable representation of the given service key.
+func formatKey(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case fmt.Stringer:
+		return k.String()
+	}
+
+	return fmt.Sprintf("%T%+v", key, key)
+}
+
+// wantsJSON returns true if the given request asks for a JSON response.
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Services</title>
+</head>
+<body>
+<h1>Services</h1>
+<p>{{.Layers}} layer(s). Layer 0 is the root container.</p>
+<table>
+<thead>
+<tr><th>Key</th><th>Tag</th><th>Type</th><th>Layer</th><th>Lifetime</th><th>Value</th></tr>
+</thead>
+<tbody>
+{{- range .Services}}
+<tr{{if .Shadowed}} class="shadowed"{{end}}><td>{{.Key}}{{if .Shadowed}} (shadowed){{end}}</td><td>{{.Tag}}</td><td>{{.Type}}</td><td>{{.Layer}}</td><td>{{.Lifetime}}</td><td>{{if .Redacted}}<em>{{.Value}}</em>{{else}}{{.Value}}{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<h2>Dependencies</h2>
+<table>
+<thead>
+<tr><th>Service</th><th>Depends on</th></tr>
+</thead>
+<tbody>
+{{- range .Edges}}
+<tr><td>{{.From}}</td><td>{{.To}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,143 @@
+package servicehttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph-testing/nacelle-service/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Password string
+}
+
+type testServer struct {
+	Config *testConfig `service:"config"`
+}
+
+type testDB struct{}
+
+func newTestContainer(t *testing.T) *service.Container {
+	container := service.New()
+	require.Nil(t, container.Set("config", &testConfig{Password: "hunter2"}))
+	require.Nil(t, container.Set("server", &testServer{}))
+	require.Nil(t, container.Provide(func() *testDB { return &testDB{} }))
+	require.Nil(t, container.SetTransient("request", func(ctx context.Context, c *service.Container) (interface{}, error) {
+		return "<script>", nil
+	}))
+
+	scope := container.NewScope()
+	require.Nil(t, scope.SetLocal("request", "<b>local</b>"))
+	return scope
+}
+
+func serve(t *testing.T, h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerJSON(t *testing.T) {
+	w := serve(t, NewHandler(newTestContainer(t)), "/?format=json", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var doc document
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, 2, doc.Layers)
+	assert.Equal(t, []serviceEntry{
+		{Key: "config", Tag: "config", Type: "*servicehttp.testConfig", Layer: 0, Lifetime: "singleton"},
+		{Key: "server", Tag: "server", Type: "*servicehttp.testServer", Layer: 0, Lifetime: "singleton"},
+		{Key: "*servicehttp.testDB", Type: "*servicehttp.testDB", Layer: 0, Lifetime: "singleton"},
+		{Key: "request", Tag: "request", Layer: 0, Shadowed: true, Lifetime: "transient"},
+		{Key: "request", Tag: "request", Type: "string", Layer: 1, Lifetime: "singleton"},
+	}, doc.Services)
+	assert.Equal(t, []edge{{From: "server", To: "config"}}, doc.Edges)
+	assert.NotContains(t, w.Body.String(), "hunter2")
+
+	// Accept header
+	w = serve(t, NewHandler(newTestContainer(t)), "/", http.Header{"Accept": {"application/json"}})
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestHandlerHTML(t *testing.T) {
+	w := serve(t, NewHandler(newTestContainer(t)), "/", http.Header{"Accept": {"text/html"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "<td>*servicehttp.testConfig</td>")
+	assert.Contains(t, body, "<td>request (shadowed)</td>")
+	assert.Contains(t, body, "<tr><td>server</td><td>config</td></tr>")
+	assert.NotContains(t, body, "hunter2")
+	assert.NotContains(t, body, "local")
+}
+
+func TestHandlerShowValues(t *testing.T) {
+	w := serve(t, NewHandler(newTestContainer(t), ShowValues()), "/?format=json", nil)
+
+	var doc document
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "&{Password:hunter2}", doc.Services[0].Value)
+	assert.Equal(t, "&{Config:<nil>}", doc.Services[1].Value)
+	assert.Equal(t, "", doc.Services[2].Value)
+	assert.Equal(t, "<b>local</b>", doc.Services[4].Value)
+
+	w = serve(t, NewHandler(newTestContainer(t), ShowValues()), "/", nil)
+	assert.Contains(t, w.Body.String(), "&lt;b&gt;local&lt;/b&gt;")
+	assert.NotContains(t, w.Body.String(), "<b>local</b>")
+}
+
+func TestHandlerRedactor(t *testing.T) {
+	redactor := func(entry service.Entry) (string, bool) {
+		if entry.Type == reflect.TypeOf(&testConfig{}) {
+			return "", true
+		}
+		if entry.Key == "request" {
+			return "<request>", true
+		}
+
+		return "", false
+	}
+
+	w := serve(t, NewHandler(newTestContainer(t), ShowValues(), WithRedactor(redactor)), "/?format=json", nil)
+
+	var doc document
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "[redacted]", doc.Services[0].Value)
+	assert.True(t, doc.Services[0].Redacted)
+	assert.Equal(t, "&{Config:<nil>}", doc.Services[1].Value)
+	assert.Equal(t, "<request>", doc.Services[4].Value)
+	assert.NotContains(t, w.Body.String(), "hunter2")
+
+	w = serve(t, NewHandler(newTestContainer(t), ShowValues(), WithRedactor(redactor)), "/", nil)
+	assert.NotContains(t, w.Body.String(), "hunter2")
+	assert.Contains(t, w.Body.String(), "<em>[redacted]</em>")
+
+	// The redactor is not consulted unless values are displayed
+	w = serve(t, NewHandler(newTestContainer(t), WithRedactor(redactor)), "/?format=json", nil)
+
+	var hidden document
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &hidden))
+	assert.Equal(t, "", hidden.Services[1].Value)
+	assert.False(t, hidden.Services[0].Redacted)
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	w := httptest.NewRecorder()
+	NewHandler(service.New()).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	serviceTag  = "service"
+	optionalTag = "optional"
+
+	typeOption     = "type"
+	inlineOption   = "inline"
+	optionalOption = "optional"
+	strictOption   = "strict"
+	defaultOption  = "default"
+
+	groupPrefix = "group:"
+)
+
+// fieldTag is the parsed form of the injection tags of a struct field. The service tag has the form
+// `service:"key,option,..."`, or `service:"group:name,option,..."` for a slice or map[string] field
+// populated by every member of a group (see Container.AddToGroup). Each option is one of:
+//
+//   - type: match the field by type rather than by key (the key must be empty)
+//   - inline: inject the fields of a struct or pointer-to-struct field recursively (the key must be
+//     empty and no other options may be given)
+//   - optional: leave the field unset if no service is registered
+//   - strict: require a service assignable to the field's type (see WithStrictTypes)
+//   - default=key: use the service registered to the given key if the field's service is missing
+//
+// The legacy `optional:"true"` tag is also honored.
+type fieldTag struct {
+	key      string
+	group    string
+	fallback string
+	byType   bool
+	inline   bool
+	optional bool
+	strict   bool
+}
+
+// parseFieldTag parses the injection tags of the given struct field. The returned flag is false if
+// the field has no service tag and does not participate in injection.
+func parseFieldTag(field reflect.StructField) (fieldTag, bool, error) {
+	value := field.Tag.Get(serviceTag)
+	if value == "" {
+		return fieldTag{}, false, nil
+	}
+
+	parts := strings.Split(value, ",")
+	tag := fieldTag{key: parts[0]}
+	for _, option := range parts[1:] {
+		name, arg, hasArg := strings.Cut(option, "=")
+
+		switch {
+		case name == typeOption && !hasArg:
+			tag.byType = true
+		case name == inlineOption && !hasArg:
+			tag.inline = true
+		case name == optionalOption && !hasArg:
+			tag.optional = true
+		case name == strictOption && !hasArg:
+			tag.strict = true
+		case name == defaultOption && hasArg && arg != "":
+			tag.fallback = arg
+		case name == defaultOption:
+			return tag, true, invalidServiceTag(field, "option default requires a key")
+		default:
+			return tag, true, invalidServiceTag(field, fmt.Sprintf("unknown option %q", option))
+		}
+	}
+
+	if tag.inline {
+		if value != ","+inlineOption {
+			return tag, true, invalidServiceTag(field, "option inline cannot be combined with a service key or other options")
+		}
+		if !isStructOrStructPointer(field.Type) {
+			return tag, true, invalidServiceTag(field, "option inline requires a struct or pointer to struct field")
+		}
+
+		return tag, true, nil
+	}
+	if group, ok := strings.CutPrefix(tag.key, groupPrefix); ok {
+		if err := checkGroupTag(field, group, tag); err != nil {
+			return tag, true, err
+		}
+
+		tag.key = ""
+		tag.group = group
+	}
+	if tag.key == "" && tag.group == "" && !tag.byType {
+		return tag, true, invalidServiceTag(field, "missing service key")
+	}
+	if tag.key != "" && tag.byType {
+		return tag, true, invalidServiceTag(field, "option type cannot be combined with a service key")
+	}
+
+	if optionalValue := field.Tag.Get(optionalTag); optionalValue != "" {
+		val, err := strconv.ParseBool(optionalValue)
+		if err != nil {
+			return tag, true, &InvalidTagError{Field: field.Name, Tag: optionalTag}
+		}
+
+		tag.optional = tag.optional || val
+	}
+
+	return tag, true, nil
+}
+
+// checkGroupTag returns an error if the given group name and options cannot be used to populate the
+// given field.
+func checkGroupTag(field reflect.StructField, group string, tag fieldTag) error {
+	if group == "" {
+		return invalidServiceTag(field, "missing group name")
+	}
+	if tag.byType {
+		return invalidServiceTag(field, "option type cannot be combined with a group")
+	}
+	if tag.fallback != "" {
+		return invalidServiceTag(field, "option default cannot be combined with a group")
+	}
+
+	t := field.Type
+	if t.Kind() != reflect.Slice && !(t.Kind() == reflect.Map && t.Key().Kind() == reflect.String) {
+		return invalidServiceTag(field, "a group requires a slice or map with string keys")
+	}
+
+	return nil
+}
+
+// isStructOrStructPointer returns true if the given type is a struct or a pointer to a struct.
+func isStructOrStructPointer(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct
+}
+
+func invalidServiceTag(field reflect.StructField, reason string) error {
+	return &InvalidTagError{Field: field.Name, Tag: serviceTag, Reason: reason}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFieldTag(t *testing.T) {
+	type T struct {
+		Untagged int
+		Key      int `service:"value"`
+		ByType   int `service:",type"`
+		Options  int `service:"value,optional,default=fallback,strict"`
+		Legacy   int `service:"value" optional:"true"`
+		Disabled int `service:"value,optional" optional:"false"`
+	}
+
+	typ := reflect.TypeOf(T{})
+	parse := func(name string) (fieldTag, bool) {
+		field, _ := typ.FieldByName(name)
+		tag, ok, err := parseFieldTag(field)
+		require.Nil(t, err)
+		return tag, ok
+	}
+
+	_, ok := parse("Untagged")
+	assert.False(t, ok)
+
+	tag, ok := parse("Key")
+	assert.True(t, ok)
+	assert.Equal(t, fieldTag{key: "value"}, tag)
+
+	tag, _ = parse("ByType")
+	assert.Equal(t, fieldTag{byType: true}, tag)
+
+	tag, _ = parse("Options")
+	assert.Equal(t, fieldTag{key: "value", fallback: "fallback", optional: true, strict: true}, tag)
+
+	tag, _ = parse("Legacy")
+	assert.Equal(t, fieldTag{key: "value", optional: true}, tag)
+
+	tag, _ = parse("Disabled")
+	assert.Equal(t, fieldTag{key: "value", optional: true}, tag)
+}
+
+func TestParseFieldTagInvalid(t *testing.T) {
+	testCases := []struct {
+		tag      reflect.StructTag
+		expected string
+	}{
+		{`service:"value,optinal"`, `field 'Value' has an invalid service tag: unknown option "optinal"`},
+		{`service:"value,strict=true"`, `field 'Value' has an invalid service tag: unknown option "strict=true"`},
+		{`service:"value,default"`, "field 'Value' has an invalid service tag: option default requires a key"},
+		{`service:"value,default="`, "field 'Value' has an invalid service tag: option default requires a key"},
+		{`service:",optional"`, "field 'Value' has an invalid service tag: missing service key"},
+		{`service:"value,type"`, "field 'Value' has an invalid service tag: option type cannot be combined with a service key"},
+		{`service:"value" optional:"yup"`, "field 'Value' has an invalid optional tag"},
+	}
+
+	for _, testCase := range testCases {
+		_, ok, err := parseFieldTag(reflect.StructField{Name: "Value", Tag: testCase.tag})
+		assert.True(t, ok)
+		assert.EqualError(t, err, testCase.expected)
+		assert.True(t, errors.Is(err, ErrInvalidTag))
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"reflect"
+)
+
+// Key is a service key that carries the type of the service registered to it. Key implements
+// InjectableServiceKey using its string value as its tag, so Key[T]("db") and the string key "db"
+// are equivalent within a container and may be used interchangeably with struct tags.
+type Key[T any] string
+
+// Tag returns the string value of the key.
+func (k Key[T]) Tag() string {
+	return string(k)
+}
+
+// Get retrieves the service registered to the given typed key. It is an error for a service not
+// to be registered to this key or for the service to not be a value of type T.
+func Get[T any](c *Container, key Key[T]) (T, error) {
+	return GetContext(context.Background(), c, key)
+}
+
+// GetContext retrieves the service registered to the given typed key. The given context is passed
+// to the service's factory if it has not yet been constructed (see Container.GetContext).
+func GetContext[T any](ctx context.Context, c *Container, key Key[T]) (T, error) {
+	var zero T
+	value, err := c.GetContext(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+
+	service, ok := value.(T)
+	if !ok {
+		return zero, &TypeMismatchError{Key: key, Want: reflect.TypeOf(&zero).Elem(), Got: reflect.TypeOf(value)}
+	}
+
+	return service, nil
+}
+
+// Set registers a service with the given typed key. The same duplicate key rules as Container.Set
+// apply.
+func Set[T any](c *Container, key Key[T], service T) error {
+	return c.Set(key, service)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedGetAndSet(t *testing.T) {
+	type T struct{ val int }
+
+	key := Key[*T]("a")
+	container := New()
+	require.Nil(t, Set(container, key, &T{10}))
+
+	value, err := Get(container, key)
+	require.Nil(t, err)
+	assert.Equal(t, &T{10}, value)
+	assertValue(t, container, "a", &T{10})
+}
+
+func TestTypedGetInterface(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("logger", &testLoggerImpl{"app"}))
+
+	value, err := Get(container, Key[testLogger]("logger"))
+	require.Nil(t, err)
+	assert.Equal(t, &testLoggerImpl{"app"}, value)
+}
+
+func TestTypedGetStringKey(t *testing.T) {
+	type T struct{ val int }
+
+	container := New()
+	require.Nil(t, container.Set("a", &T{10}))
+
+	value, err := Get(container, Key[*T]("a"))
+	require.Nil(t, err)
+	assert.Equal(t, &T{10}, value)
+}
+
+func TestTypedGetFactory(t *testing.T) {
+	type T struct{ val int }
+
+	key := Key[*T]("a")
+	container := New()
+	require.Nil(t, container.SetFactory(key, func(ctx context.Context, c *Container) (interface{}, error) {
+		return &T{10}, nil
+	}))
+
+	value, err := GetContext(context.Background(), container, key)
+	require.Nil(t, err)
+	assert.Equal(t, &T{10}, value)
+}
+
+func TestTypedGetTypeMismatch(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("a", 42))
+	require.Nil(t, container.Set("b", nil))
+
+	_, err := Get(container, Key[float64]("a"))
+	assert.EqualError(t, err, `service Key[float64] ("a") has type int, expected float64`)
+
+	_, err = Get(container, Key[float64]("b"))
+	assert.EqualError(t, err, `service Key[float64] ("b") has type nil, expected float64`)
+}
+
+func TestTypedGetUnknownKey(t *testing.T) {
+	_, err := Get(New(), Key[int]("a"))
+	assert.EqualError(t, err, `no service registered to key Key[int] ("a")`)
+}
+
+func TestTypedSetDuplicateKey(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Set("dup", 42))
+	assert.EqualError(t, Set(container, Key[int]("dup"), 42), `duplicate service key Key[int] ("dup")`)
+}
+
+func TestTypedInject(t *testing.T) {
+	type T1 struct{ val int }
+	type T2 struct {
+		Value *T1 `service:"value"`
+	}
+
+	container := New()
+	require.Nil(t, Set(container, Key[*T1]("value"), &T1{42}))
+	obj := &T2{}
+	err := Inject(context.Background(), container, obj)
+	require.Nil(t, err)
+	assert.Equal(t, 42, obj.Value.val)
+}
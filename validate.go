@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Validate checks that the given struct types could be injected from the container without
+// injecting a live value. Each target may be a reflect.Type or a value whose type is validated;
+// the value itself is not modified. Every tagged field, including the fields of anonymous and inline
+// struct fields, is checked for a registered service with a compatible type. Factories are not invoked
+// and PostInject hooks are not called. Services constructed by factories registered via SetFactory,
+// SetTransient, or SetScoped have no known type until first retrieved, and are assumed compatible.
+// It is an error for a target not to be a struct or a pointer to a struct. If any field would fail
+// to inject, an *InjectionError listing every such field is returned.
+func (c *Container) Validate(targets ...interface{}) error {
+	i := &injector{ctx: context.Background(), c: c, collect: true, dryRun: true}
+	for _, target := range targets {
+		t, ok := target.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(target)
+		}
+		if t == nil || !isStructOrStructPointer(t) {
+			return fmt.Errorf("expected a struct or pointer to struct, got %s", typeName(t))
+		}
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		obj := reflect.New(t)
+		if _, err := i.inject(obj.Interface(), obj, t.Name()); err != nil {
+			return err
+		}
+	}
+
+	if len(i.errs) > 0 {
+		return &InjectionError{Errors: i.errs}
+	}
+
+	return nil
+}
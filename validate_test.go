@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerValidate(t *testing.T) {
+	calls := 0
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.SetFactory("process", func(ctx context.Context, c *Container) (interface{}, error) {
+		calls++
+		return &testPostInjectProcess{}, nil
+	}))
+	require.Nil(t, container.Set("services", container))
+
+	require.Nil(t, container.Validate(reflect.TypeOf(testPostInjectProcessParent{})))
+	require.Nil(t, container.Validate(&testPostInjectProcess{}))
+	assert.Equal(t, 0, calls)
+}
+
+func TestContainerValidateErrors(t *testing.T) {
+	type T struct {
+		Value   *TI     `service:"value"`
+		Missing *TI     `service:"missing"`
+		Maybe   *TI     `service:"maybe" optional:"true"`
+		Store   *TI     `service:"store"`
+		DB      *testDB `service:",type"`
+		Nil     *TI     `service:"nil"`
+	}
+
+	container := New()
+	require.Nil(t, container.Set("value", &TI{42}))
+	require.Nil(t, container.Set("nil", nil))
+	require.Nil(t, container.Provide(func() *TF { return &TF{} }))
+	require.Nil(t, container.SetFactory("store", func(ctx context.Context, c *Container) (interface{}, error) {
+		return c.GetContext(ctx, TypeKey(reflect.TypeOf(&TF{})))
+	}))
+
+	// The type of an unconstructed factory is unknown
+	obj := &T{}
+	err := container.Validate(obj)
+	assert.EqualError(t, err, `3 fields could not be injected:
+	T.Missing ("missing"): no service registered to key "missing"
+	T.DB (*service.testDB): no service registered assignable to type *service.testDB
+	T.Nil ("nil"): field 'Nil' cannot be assigned a value of type nil`)
+	assert.Equal(t, &T{}, obj)
+
+	// Constructed services are checked by their concrete type
+	_, err = container.Get("store")
+	require.Nil(t, err)
+
+	var injectionErr *InjectionError
+	require.True(t, errors.As(container.Validate(reflect.TypeOf(T{})), &injectionErr))
+	require.Len(t, injectionErr.Errors, 4)
+	assert.Equal(t, "T.Store", injectionErr.Errors[1].Path)
+	assert.True(t, errors.Is(injectionErr.Errors[1], ErrTypeMismatch))
+}
+
+func TestContainerValidatePostInject(t *testing.T) {
+	container := New()
+	require.Nil(t, container.Validate(&testPostInjectProcessError{}))
+}
+
+func TestContainerValidateMultipleTypes(t *testing.T) {
+	type T1 struct {
+		Value *TI `service:"a"`
+	}
+	type T2 struct {
+		*T1
+		Value *TI `service:"b"`
+	}
+
+	err := New().Validate(T1{}, reflect.TypeOf(&T2{}))
+	assert.EqualError(t, err, `3 fields could not be injected:
+	T1.Value ("a"): no service registered to key "a"
+	T2.T1.Value ("a"): no service registered to key "a"
+	T2.Value ("b"): no service registered to key "b"`)
+}
+
+func TestContainerValidateInvalidTarget(t *testing.T) {
+	type T struct {
+		Value *TI `service:"a"`
+	}
+
+	container := New()
+	assert.EqualError(t, container.Validate(3), "expected a struct or pointer to struct, got int")
+	assert.EqualError(t, container.Validate(nil), "expected a struct or pointer to struct, got nil")
+	assert.EqualError(t, container.Validate(reflect.TypeOf((*testLogger)(nil)).Elem()), "expected a struct or pointer to struct, got service.testLogger")
+	assert.EqualError(t, container.Validate(T{}, new(*T)), "expected a struct or pointer to struct, got **service.T")
+}
@@ -0,0 +1,125 @@
+package service
+
+import "context"
+
+// Event describes a change to the service registered to a key watched via Watch.
+type Event struct {
+	// Key is the key to which the service is registered after the change, or the watched key if
+	// Removed is set.
+	Key interface{}
+
+	// Removed is set if no service is registered to the key after the change.
+	Removed bool
+}
+
+// Watch returns a channel that receives an event each time the registration of the service
+// registered to the given key (or a key with the same tag, see InjectableServiceKey) changes. A
+// change is any call to Set, SetLocal, Replace, Remove, or a similar method that registers, replaces,
+// or removes a service with an equivalent key in this container or one of its parent layers. Changes
+// to a parent layer are ignored while the key is shadowed by a nearer layer (see SetLocal).
+//
+// Each event describes the registration visible from this container at the time the event is
+// delivered. Events do not carry the service itself and factories are never invoked by the watcher;
+// use Get to retrieve the current service. The channel has a buffer of one and events are coalesced:
+// if the receiver falls behind, only the latest event is delivered. The subscription is removed and
+// the channel is closed once the given context is canceled.
+func (c *Container) Watch(ctx context.Context, key interface{}) <-chan Event {
+	w := &watcher{
+		c:      c,
+		key:    canonicalKey(key),
+		signal: make(chan struct{}, 1),
+	}
+
+	for layer := c; layer != nil; layer = layer.parent {
+		layer.mutex.Lock()
+		layer.watchers[w] = struct{}{}
+		layer.mutex.Unlock()
+	}
+
+	ch := make(chan Event, 1)
+
+	go func() {
+		defer close(ch)
+		defer w.unregister()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.signal:
+			}
+
+			event := Event{Key: key, Removed: true}
+			if e, _, ok := c.lookup(key); ok {
+				event = Event{Key: e.key}
+			}
+
+			// Replace any event that has not yet been received
+			select {
+			case <-ch:
+			default:
+			}
+
+			ch <- event
+		}
+	}()
+
+	return ch
+}
+
+// watcher is a subscription to changes of a service key made via Watch. A watcher is registered
+// with the container from which it was created and with each of its parent layers.
+type watcher struct {
+	c      *Container
+	key    interface{}
+	signal chan struct{}
+}
+
+// unregister removes the watcher from each layer with which it was registered.
+func (w *watcher) unregister() {
+	for layer := w.c; layer != nil; layer = layer.parent {
+		layer.mutex.Lock()
+		delete(layer.watchers, w)
+		layer.mutex.Unlock()
+	}
+}
+
+// notify signals the watchers of the given key registered with this container layer that the key
+// has changed in this layer. Watchers for which the key is shadowed by a layer between the watcher's
+// container and this layer are not signaled. This method must be called without holding the lock.
+func (c *Container) notify(key interface{}) {
+	key = canonicalKey(key)
+
+	c.mutex.RLock()
+	var watchers []*watcher
+	for w := range c.watchers {
+		if w.key == key {
+			watchers = append(watchers, w)
+		}
+	}
+	c.mutex.RUnlock()
+
+	for _, w := range watchers {
+		if w.shadowed(c, key) {
+			continue
+		}
+
+		select {
+		case w.signal <- struct{}{}:
+		default:
+			// A notification is already pending
+		}
+	}
+}
+
+// shadowed returns true if a service with the given key is registered to a layer between the
+// watcher's container and the given layer.
+func (w *watcher) shadowed(layer *Container, key interface{}) bool {
+	for l := w.c; l.registry != layer.registry; l = l.parent {
+		if _, ok := l.lookupLocal(key); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for notification")
+		return Event{}
+	}
+}
+
+func assertNoNotification(t *testing.T, ch <-chan Event) {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected notification: %v", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	container := New()
+	ch := container.Watch(ctx, "a")
+
+	require.Nil(t, container.Set("a", &TI{10}))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+
+	require.Nil(t, container.Replace("a", &TI{15}))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+
+	require.Nil(t, container.Remove("a"))
+	assert.Equal(t, Event{Key: "a", Removed: true}, receive(t, ch))
+
+	// Registered nil values are not removals
+	require.Nil(t, container.Set("a", nil))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+	require.Nil(t, container.Remove("a"))
+	assert.Equal(t, Event{Key: "a", Removed: true}, receive(t, ch))
+
+	// Tag-equivalent keys
+	require.Nil(t, container.Set(testKey1{"a"}, &TI{20}))
+	assert.Equal(t, Event{Key: testKey1{"a"}}, receive(t, ch))
+
+	// Other keys
+	require.Nil(t, container.Set("b", &TI{30}))
+	assertNoNotification(t, ch)
+}
+
+func TestWatchFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	container := New()
+	ch := container.Watch(ctx, "a")
+
+	var calls int32
+	factory := func(ctx context.Context, c *Container) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("oops")
+	}
+
+	// Factories are not invoked by the watcher
+	require.Nil(t, container.SetFactory("a", factory))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+	require.Nil(t, container.Remove("a"))
+	assert.Equal(t, Event{Key: "a", Removed: true}, receive(t, ch))
+	require.Nil(t, container.SetTransient("a", factory))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestWatchCoalesce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	container := New()
+	ch := container.Watch(ctx, "a")
+
+	require.Nil(t, container.Set("a", &TI{10}))
+	for i := 11; i <= 20; i++ {
+		require.Nil(t, container.Replace("a", &TI{i}))
+	}
+
+	// Intermediate events may be skipped, but an event follows the latest change
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+	for drained := false; !drained; {
+		select {
+		case event := <-ch:
+			assert.Equal(t, Event{Key: "a"}, event)
+		case <-time.After(20 * time.Millisecond):
+			drained = true
+		}
+	}
+
+	value, err := container.Get("a")
+	require.Nil(t, err)
+	assert.Equal(t, &TI{20}, value)
+}
+
+func TestWatchOverlay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	container := New()
+	require.Nil(t, container.Set("a", &TI{10}))
+
+	scope := container.NewScope()
+	ch := scope.Watch(ctx, "a")
+
+	// Changes to parent layers are visible
+	require.Nil(t, container.Replace("a", &TI{15}))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+
+	// Changes to the scope are visible
+	require.Nil(t, scope.SetLocal("a", &TI{20}))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+
+	// Changes to shadowed parent services are not visible
+	require.Nil(t, container.Replace("a", &TI{25}))
+	assertNoNotification(t, ch)
+
+	// Removing the local service reveals the parent service
+	require.Nil(t, scope.Remove("a"))
+	assert.Equal(t, Event{Key: "a"}, receive(t, ch))
+
+	// Changes to sibling scopes are not visible
+	require.Nil(t, container.NewScope().SetLocal("a", &TI{30}))
+	assertNoNotification(t, ch)
+}
+
+func TestWatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	container := New()
+	scope := container.NewScope()
+	ch := scope.Watch(ctx, "a")
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for channel to close")
+	}
+
+	for _, layer := range []*Container{container, scope} {
+		layer.mutex.RLock()
+		assert.Empty(t, layer.watchers)
+		layer.mutex.RUnlock()
+	}
+
+	require.Nil(t, container.Set("a", &TI{10}))
+}